@@ -0,0 +1,154 @@
+package cmds
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/thetatoken/theta/common"
+	"github.com/thetatoken/theta/crypto"
+	"github.com/thetatoken/theta/wallet/softwallet/keystore"
+)
+
+var keysDir string
+var password string
+
+// keysCmd represents the keys command
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage the keys of the local encrypted keystore",
+}
+
+// keysNewCmd represents the keys new command
+var keysNewCmd = &cobra.Command{
+	Use:   "new",
+	Short: "Generate a new key and store it in the keystore",
+	Run:   runKeysNew,
+}
+
+// keysListCmd represents the keys list command
+var keysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the addresses of the keys in the keystore",
+	Run:   runKeysList,
+}
+
+// keysImportCmd represents the keys import command
+var keysImportCmd = &cobra.Command{
+	Use:   "import [hex private key]",
+	Short: "Import a private key into the keystore",
+	Args:  cobra.ExactArgs(1),
+	Run:   runKeysImport,
+}
+
+func init() {
+	keysCmd.PersistentFlags().StringVar(&keysDir, "keys-dir", "", "keystore directory (default is <config>/keys/encrypted)")
+	keysCmd.PersistentFlags().StringVar(&password, "password", "", "keystore password, prompted for when not set (discouraged, it ends up in the shell history)")
+	keysCmd.AddCommand(keysNewCmd)
+	keysCmd.AddCommand(keysListCmd)
+	keysCmd.AddCommand(keysImportCmd)
+	RootCmd.AddCommand(keysCmd)
+}
+
+func runKeysNew(cmd *cobra.Command, args []string) {
+	privKey, _, err := crypto.GenerateKeyPair()
+	if err != nil {
+		exitWithError("Failed to generate key: %v", err)
+	}
+	storeKey(privKey)
+}
+
+func runKeysList(cmd *cobra.Command, args []string) {
+	ks := openKeystore()
+	addresses, err := ks.ListKeyAddresses()
+	if err != nil {
+		exitWithError("Failed to list keys: %v", err)
+	}
+	for _, address := range addresses {
+		fmt.Println(address.Hex())
+	}
+}
+
+func runKeysImport(cmd *cobra.Command, args []string) {
+	privKeyBytes, err := hex.DecodeString(strings.TrimPrefix(args[0], "0x"))
+	if err != nil {
+		exitWithError("Invalid private key: %v", err)
+	}
+	privKey, err := crypto.PrivateKeyFromBytes(privKeyBytes)
+	if err != nil {
+		exitWithError("Invalid private key: %v", err)
+	}
+	storeKey(privKey)
+}
+
+func storeKey(privKey *crypto.PrivateKey) {
+	ks := openKeystore()
+	auth := getPassword(true)
+	key := keystore.NewKey(privKey)
+	if err := ks.StoreKey(key, auth); err != nil {
+		exitWithError("Failed to store key: %v", err)
+	}
+	fmt.Println(key.Address.Hex())
+}
+
+func getKey(address common.Address) *keystore.Key {
+	ks := openKeystore()
+	key, err := ks.GetKey(address, getPassword(false))
+	if err != nil {
+		exitWithError("Failed to unlock key %v: %v", address.Hex(), err)
+	}
+	return key
+}
+
+func openKeystore() keystore.Keystore {
+	dir := keysDir
+	if dir == "" {
+		dir = path.Join(cfgPath, "keys", "encrypted")
+	}
+	ks, err := keystore.NewKeystoreEncrypted(dir, keystore.StandardScryptN, keystore.StandardScryptP)
+	if err != nil {
+		exitWithError("Failed to open keystore %v: %v", dir, err)
+	}
+	return ks
+}
+
+func getPassword(confirm bool) string {
+	if password != "" {
+		fmt.Fprintln(os.Stderr, "Warning: a password passed with --password is kept in the shell history")
+		return password
+	}
+	reader := bufio.NewReader(os.Stdin)
+	auth := readPassword(reader, "Password: ")
+	if confirm && auth != readPassword(reader, "Repeat password: ") {
+		exitWithError("Passwords do not match")
+	}
+	return auth
+}
+
+// readPassword prompts for a password without echoing it on a terminal. A password piped
+// through stdin is read as a line.
+func readPassword(reader *bufio.Reader, prompt string) string {
+	fmt.Fprint(os.Stderr, prompt)
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		auth, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			exitWithError("Failed to read password: %v", err)
+		}
+		return string(auth)
+	}
+	auth, _ := reader.ReadString('\n')
+	return strings.TrimRight(auth, "\r\n")
+}
+
+func exitWithError(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,119 @@
+package cmds
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/spf13/cobra"
+
+	cmn "github.com/thetatoken/theta-rosetta-rpc-adaptor/common"
+	"github.com/thetatoken/theta-rosetta-rpc-adaptor/services"
+
+	"github.com/thetatoken/theta/common"
+	"github.com/thetatoken/theta/crypto"
+	"github.com/thetatoken/theta/crypto/secp256k1"
+	ttypes "github.com/thetatoken/theta/ledger/types"
+)
+
+var unsignedTx string
+var signingPayload string
+var chainID string
+
+// signCmd represents the sign command
+var signCmd = &cobra.Command{
+	Use:   "sign",
+	Short: "Sign a /construction/payloads signing payload with a key from the local keystore",
+	Long: `Sign a /construction/payloads signing payload with a key from the local keystore.
+The signature is printed as JSON, ready to be passed to /construction/combine.`,
+	Run: runSign,
+}
+
+func init() {
+	signCmd.Flags().StringVar(&unsignedTx, "unsigned-tx", "", "unsigned transaction returned by /construction/payloads")
+	signCmd.Flags().StringVar(&signingPayload, "payload", "", "signing payload JSON returned by /construction/payloads")
+	signCmd.Flags().StringVar(&chainID, "chain", "", "chain ID the payload is checked against (default is the chain ID of the first configured network)")
+	signCmd.Flags().StringVar(&keysDir, "keys-dir", "", "keystore directory (default is <config>/keys/encrypted)")
+	signCmd.Flags().StringVar(&password, "password", "", "keystore password, prompted for when not set (discouraged, it ends up in the shell history)")
+	signCmd.MarkFlagRequired("unsigned-tx")
+	signCmd.MarkFlagRequired("payload")
+	RootCmd.AddCommand(signCmd)
+}
+
+func runSign(cmd *cobra.Command, args []string) {
+	payload := &types.SigningPayload{}
+	if err := json.Unmarshal([]byte(signingPayload), payload); err != nil {
+		exitWithError("Invalid signing payload: %v", err)
+	}
+	if payload.AccountIdentifier == nil || len(payload.Bytes) != 32 {
+		exitWithError("Signing payload must have an account identifier and a 32-byte hash")
+	}
+	address := common.HexToAddress(payload.AccountIdentifier.Address)
+
+	rawTx, err := hex.DecodeString(unsignedTx)
+	if err != nil {
+		exitWithError("Invalid unsigned transaction: %v", err)
+	}
+	tx, err := ttypes.TxFromBytes(rawTx)
+	if err != nil {
+		exitWithError("Invalid unsigned transaction: %v", err)
+	}
+
+	isSigner := false
+	for _, signer := range cmn.GetTxSigners(tx) {
+		if signer == address {
+			isSigner = true
+		}
+	}
+	if !isSigner {
+		exitWithError("%v is not a signer of the transaction", address.Hex())
+	}
+
+	// never sign a payload blindly, it must be the sign bytes hash of the transaction
+	if chainID == "" {
+		networks, err := cmn.GetNetworkConfigs()
+		if err != nil {
+			exitWithError("Invalid network config: %v", err)
+		}
+		chainID = networks[0].ChainID
+	}
+	if chainID == "" {
+		exitWithError("Chain ID is required, set --chain or the chainID of the network in the config")
+	}
+	hash := crypto.Keccak256Hash(cmn.GetTxSignBytes(tx, address, chainID))
+	if !bytes.Equal(hash.Bytes(), payload.Bytes) {
+		exitWithError("Signing payload does not match the transaction on chain %v", chainID)
+	}
+
+	key := getKey(address)
+	sigBytes, err := secp256k1.Sign(payload.Bytes, key.PrivateKey.ToBytes())
+	if err != nil {
+		exitWithError("Failed to sign: %v", err)
+	}
+
+	signatureType := types.SignatureType(services.SignatureType)
+	if payload.SignatureType != "" {
+		signatureType = payload.SignatureType
+	}
+	if signatureType == types.SignatureType(services.SignatureTypeEcdsa) {
+		sigBytes = sigBytes[:64] // drop the recovery id
+	}
+
+	signature := &types.Signature{
+		SigningPayload: payload,
+		PublicKey: &types.PublicKey{
+			Bytes:     key.PrivateKey.PublicKey().ToBytes(),
+			CurveType: services.CurveType,
+		},
+		SignatureType: signatureType,
+		Bytes:         sigBytes,
+	}
+
+	out, err := json.MarshalIndent(signature, "", "  ")
+	if err != nil {
+		exitWithError("Failed to encode signature: %v", err)
+	}
+	fmt.Println(string(out))
+}
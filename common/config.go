@@ -36,10 +36,39 @@ const (
 	CfgRosettaMode        = "rosetta.mode"
 	CfgRosettaModeOnline  = "online"
 	CfgRosettaModeOffline = "offline"
+
+	// CfgRosettaTokens lists the TNT-20 tokens (symbol, decimals, contractAddress) supported by construction.
+	CfgRosettaTokens = "rosetta.tokens"
+	// CfgRosettaGasEstimationMargin is the safety margin, in percent, added to the estimated gas of a SmartContractTx.
+	CfgRosettaGasEstimationMargin = "rosetta.gasEstimationMargin"
+	// CfgRosettaNonceReservationTTLSecs sets how long the sequence of a submitted tx stays reserved.
+	CfgRosettaNonceReservationTTLSecs = "rosetta.nonceReservationTTLSecs"
+	// CfgRosettaNonceBuildReservationTTLSecs sets how long the sequence handed out by /construction/metadata
+	// stays reserved while the tx is not submitted.
+	CfgRosettaNonceBuildReservationTTLSecs = "rosetta.nonceBuildReservationTTLSecs"
+	// CfgRosettaSubmitTimeoutSecs sets the default timeout of a submit waiting for its tx.
+	CfgRosettaSubmitTimeoutSecs = "rosetta.submitTimeoutSecs"
+	// CfgRosettaMempoolCacheTTLSecs sets how long a decoded mempool entry is kept for /mempool filters.
+	CfgRosettaMempoolCacheTTLSecs = "rosetta.mempoolCacheTTLSecs"
+	// CfgRosettaStatePruningRetainedBlocks mirrors the node's storage.statePruningRetainedBlocks. When 0,
+	// the oldest block with queryable state is probed from the node.
+	CfgRosettaStatePruningRetainedBlocks = "rosetta.statePruningRetainedBlocks"
+	// CfgRosettaNetworks lists the networks (chainID, rpcEndpoint, dataPath) served by the adaptor.
+	// When empty, the single network of theta.rpcEndpoint is served.
+	CfgRosettaNetworks = "rosetta.networks"
+	// CfgRosettaSubchains lists the subchains (chainID, rpcEndpoint, dataPath) of the network of
+	// theta.rpcEndpoint, when rosetta.networks is not set.
+	CfgRosettaSubchains = "rosetta.subchains"
+	// CfgRosettaTokenBanks lists the Metachain token bank contracts whose events are parsed into
+	// cross-chain transfer operations.
+	CfgRosettaTokenBanks = "rosetta.tokenBanks"
+	// CfgDataPath sets the default directory of the return stakes DB.
+	CfgDataPath = "data.path"
 )
 
 func init() {
 	viper.SetDefault(CfgThetaRPCEndpoint, "http://127.0.0.1:16888/rpc")
+	viper.SetDefault(CfgDataPath, "/data")
 
 	viper.SetDefault(CfgRPCHttpAddress, "0.0.0.0")
 	viper.SetDefault(CfgRPCHttpPort, "8080")
@@ -53,4 +82,10 @@ func init() {
 
 	viper.SetDefault(CfgRosettaVersion, "1.1.1")
 	viper.SetDefault(CfgRosettaMode, "online")
+	viper.SetDefault(CfgRosettaGasEstimationMargin, 20)
+	viper.SetDefault(CfgRosettaNonceReservationTTLSecs, 600)
+	viper.SetDefault(CfgRosettaNonceBuildReservationTTLSecs, 60)
+	viper.SetDefault(CfgRosettaSubmitTimeoutSecs, 60)
+	viper.SetDefault(CfgRosettaMempoolCacheTTLSecs, 30)
+	viper.SetDefault(CfgRosettaStatePruningRetainedBlocks, 0)
 }
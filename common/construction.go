@@ -0,0 +1,910 @@
+package common
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+
+	cmn "github.com/thetatoken/theta/common"
+	"github.com/thetatoken/theta/crypto"
+	"github.com/thetatoken/theta/crypto/bls"
+	ttypes "github.com/thetatoken/theta/ledger/types"
+)
+
+// DefaultContractGasLimit is the gas limit of a smart contract tx when none is given.
+const DefaultContractGasLimit = uint64(10000000)
+
+// TxMetadataKeys lists the tx specific metadata carried from /construction/preprocess through
+// /construction/metadata to /construction/payloads.
+var TxMetadataKeys = []string{
+	"holder",
+	"beneficiary",
+	"purpose",
+	"split_basis_point",
+	"bls_pub_key",
+	"bls_pop",
+	"holder_sig",
+	"collateral",
+	"resource_ids",
+	"resource_id",
+	"duration",
+	"reserve_sequence",
+	"payment_sequence",
+	"splits",
+}
+
+// constructionTxTypes are the tx types that can be built through the construction API.
+var constructionTxTypes = map[TxType]bool{
+	SendTx:                    true,
+	ReserveFundTx:             true,
+	ReleaseFundTx:             true,
+	ServicePaymentTx:          true,
+	SplitRuleTx:               true,
+	SmartContractTx:           true,
+	DepositStakeTx:            true,
+	DepositStakeV2Tx:          true,
+	WithdrawStakeTx:           true,
+	StakeRewardDistributionTx: true,
+}
+
+// IsConstructionTxType returns true if the tx type can be built through the construction API.
+func IsConstructionTxType(txType TxType) bool {
+	return constructionTxTypes[txType]
+}
+
+// ToTxType converts a metadata value, either the numeric tx type or its name, into a TxType.
+func ToTxType(v interface{}) (TxType, bool) {
+	if txType, ok := v.(TxType); ok {
+		return txType, true
+	}
+	if name, ok := v.(string); ok {
+		for i, typ := range TxTypes() {
+			if strings.EqualFold(name, typ) {
+				return TxType(i), true
+			}
+		}
+	}
+	n, ok := ToUint64(v)
+	if !ok || n >= uint64(len(TxTypes())) {
+		return 0, false
+	}
+	return TxType(n), true
+}
+
+// InferTxType guesses the tx type of operations sent without an explicit type.
+func InferTxType(ops []*types.Operation) TxType {
+	for _, op := range ops {
+		if op.Type == SendTxInput.String() {
+			return SendTx
+		}
+	}
+	return SmartContractTx
+}
+
+// GetTokenTransfer returns the TNT-20 token moved by a SmartContractTx operation pair,
+// or nil if the operations do not describe a configured token transfer.
+func GetTokenTransfer(ops []*types.Operation) *Token {
+	if len(ops) != 2 {
+		return nil
+	}
+	var token *Token
+	for _, op := range ops {
+		if op.Amount == nil {
+			return nil
+		}
+		opToken := GetTokenFromCurrency(op.Amount.Currency)
+		if opToken == nil || (token != nil && opToken.ContractAddress != token.ContractAddress) {
+			return nil
+		}
+		token = opToken
+	}
+	return token
+}
+
+// GetSendTxInputsOutputs groups SendTx operations by account, keeping the order in which the
+// accounts first appear. The fee is paid by the first input, which must own the TxFee operation.
+// sequenceOf may be nil when only validating the operations.
+func GetSendTxInputsOutputs(ops []*types.Operation, sequenceOf func(addr cmn.Address) uint64) (inputs []ttypes.TxInput, outputs []ttypes.TxOutput, fee *big.Int, err error) {
+	inputIdx := make(map[cmn.Address]int)
+	outputIdx := make(map[cmn.Address]int)
+
+	for _, op := range ops {
+		if op.Account == nil || op.Amount == nil || op.Amount.Currency == nil {
+			return nil, nil, nil, fmt.Errorf("operation account and amount are required")
+		}
+		addr := cmn.HexToAddress(op.Account.Address)
+		amount, isTheta, e := getOpAmount(op)
+		if e != nil {
+			return nil, nil, nil, e
+		}
+
+		switch op.Type {
+		case SendTxInput.String():
+			if amount.Sign() > 0 {
+				return nil, nil, nil, fmt.Errorf("input amount must be negative or zero")
+			}
+			i, ok := inputIdx[addr]
+			if !ok {
+				i = len(inputs)
+				inputIdx[addr] = i
+				inputs = append(inputs, ttypes.TxInput{
+					Address: addr,
+					Coins:   ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(0)},
+				})
+				if sequenceOf != nil {
+					inputs[i].Sequence = sequenceOf(addr)
+				}
+			}
+			amount.Neg(amount)
+			if isTheta {
+				inputs[i].Coins.ThetaWei.Add(inputs[i].Coins.ThetaWei, amount)
+			} else {
+				inputs[i].Coins.TFuelWei.Add(inputs[i].Coins.TFuelWei, amount)
+			}
+		case SendTxOutput.String():
+			if amount.Sign() < 0 {
+				return nil, nil, nil, fmt.Errorf("output amount must be positive or zero")
+			}
+			i, ok := outputIdx[addr]
+			if !ok {
+				i = len(outputs)
+				outputIdx[addr] = i
+				outputs = append(outputs, ttypes.TxOutput{
+					Address: addr,
+					Coins:   ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(0)},
+				})
+			}
+			if isTheta {
+				outputs[i].Coins.ThetaWei.Add(outputs[i].Coins.ThetaWei, amount)
+			} else {
+				outputs[i].Coins.TFuelWei.Add(outputs[i].Coins.TFuelWei, amount)
+			}
+		case TxFee.String():
+			if fee != nil || isTheta || amount.Sign() > 0 {
+				return nil, nil, nil, fmt.Errorf("expecting a single non-positive TFUEL fee operation")
+			}
+			if len(inputs) == 0 || inputs[0].Address != addr {
+				return nil, nil, nil, fmt.Errorf("fee must be paid by the first input account")
+			}
+			fee = new(big.Int).Neg(amount)
+		default:
+			return nil, nil, nil, fmt.Errorf("unexpected operation type %v", op.Type)
+		}
+	}
+
+	if len(inputs) == 0 || len(outputs) == 0 || fee == nil {
+		return nil, nil, nil, fmt.Errorf("SendTx requires inputs, outputs and a fee operation")
+	}
+	for addr := range inputIdx {
+		if _, ok := outputIdx[addr]; ok {
+			return nil, nil, nil, fmt.Errorf("from and to accounts are the same")
+		}
+	}
+
+	inputs[0].Coins.TFuelWei.Add(inputs[0].Coins.TFuelWei, fee)
+	return
+}
+
+// AssembleTx builds the unsigned tx of the given operations. The tx type, sequences and the tx
+// specific fields come from the metadata returned by /construction/metadata.
+func AssembleTx(ops []*types.Operation, meta map[string]interface{}) (tx ttypes.Tx, err error) {
+	typ, ok := meta["type"]
+	if !ok {
+		return nil, fmt.Errorf("missing tx type")
+	}
+	txType, ok := ToTxType(typ)
+	if !ok {
+		return nil, fmt.Errorf("invalid tx type %v", typ)
+	}
+
+	sequence, ok, err := getMetadataUint64(meta, "sequence")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("missing tx sequence")
+	}
+
+	// every signer of a multi-signer tx signs with its own sequence
+	seqs := make(map[string]uint64)
+	if _, err = decodeMetadata(meta, "sequences", &seqs); err != nil {
+		return nil, err
+	}
+	sequences := make(map[cmn.Address]uint64)
+	for addr, seq := range seqs {
+		sequences[cmn.HexToAddress(addr)] = seq
+	}
+	sequenceOf := func(addr cmn.Address) uint64 {
+		if seq, ok := sequences[addr]; ok {
+			return seq
+		}
+		return sequence
+	}
+
+	switch txType {
+	case SendTx:
+		inputs, outputs, fee, err := GetSendTxInputsOutputs(ops, sequenceOf)
+		if err != nil {
+			return nil, err
+		}
+		tx = &ttypes.SendTx{
+			Fee:     ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: fee},
+			Inputs:  inputs,
+			Outputs: outputs,
+		}
+
+	case ReserveFundTx:
+		source, coins, err := getSourceCoins(ops, ReserveFundTxSource)
+		if err != nil {
+			return nil, err
+		}
+		fee, err := getFee(ops, meta)
+		if err != nil {
+			return nil, err
+		}
+		var collateral ttypes.Coins
+		if err = decodeRequiredMetadata(meta, "collateral", &collateral); err != nil {
+			return nil, err
+		}
+		var resourceIDs []string
+		if err = decodeRequiredMetadata(meta, "resource_ids", &resourceIDs); err != nil {
+			return nil, err
+		}
+		var duration uint64
+		if err = decodeRequiredMetadata(meta, "duration", &duration); err != nil {
+			return nil, err
+		}
+
+		tx = &ttypes.ReserveFundTx{
+			Fee: fee,
+			Source: ttypes.TxInput{
+				Address:  source,
+				Coins:    coins,
+				Sequence: sequenceOf(source),
+			},
+			Collateral:  collateral.NoNil(),
+			ResourceIDs: resourceIDs,
+			Duration:    duration,
+		}
+
+	case ReleaseFundTx:
+		source, coins, err := getSourceCoins(ops, ReleaseFundTxSource)
+		if err != nil {
+			return nil, err
+		}
+		fee, err := getFee(ops, meta)
+		if err != nil {
+			return nil, err
+		}
+		var reserveSequence uint64
+		if err = decodeRequiredMetadata(meta, "reserve_sequence", &reserveSequence); err != nil {
+			return nil, err
+		}
+
+		tx = &ttypes.ReleaseFundTx{
+			Fee: fee,
+			Source: ttypes.TxInput{
+				Address:  source,
+				Coins:    coins,
+				Sequence: sequenceOf(source),
+			},
+			ReserveSequence: reserveSequence,
+		}
+
+	case ServicePaymentTx:
+		source, sourceCoins, err := getSourceCoins(ops, ServicePaymentTxSource)
+		if err != nil {
+			return nil, err
+		}
+		target, targetCoins, ok, err := collectCoins(ops, ServicePaymentTxTarget)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("missing %v operation", ServicePaymentTxTarget)
+		}
+		fee, err := getFee(ops, meta)
+		if err != nil {
+			return nil, err
+		}
+		var paymentSequence, reserveSequence uint64
+		if err = decodeRequiredMetadata(meta, "payment_sequence", &paymentSequence); err != nil {
+			return nil, err
+		}
+		if err = decodeRequiredMetadata(meta, "reserve_sequence", &reserveSequence); err != nil {
+			return nil, err
+		}
+		var resourceID string
+		if err = decodeRequiredMetadata(meta, "resource_id", &resourceID); err != nil {
+			return nil, err
+		}
+
+		tx = &ttypes.ServicePaymentTx{
+			Fee: fee,
+			Source: ttypes.TxInput{
+				Address:  source,
+				Coins:    sourceCoins,
+				Sequence: sequenceOf(source),
+			},
+			Target: ttypes.TxInput{
+				Address:  target,
+				Coins:    targetCoins,
+				Sequence: sequenceOf(target),
+			},
+			PaymentSequence: paymentSequence,
+			ReserveSequence: reserveSequence,
+			ResourceID:      resourceID,
+		}
+
+	case SplitRuleTx:
+		initiator, coins, err := getSourceCoins(ops, SplitRuleTxInitiator)
+		if err != nil {
+			return nil, err
+		}
+		fee, err := getFee(ops, meta)
+		if err != nil {
+			return nil, err
+		}
+		var resourceID string
+		if err = decodeRequiredMetadata(meta, "resource_id", &resourceID); err != nil {
+			return nil, err
+		}
+		var splits []ttypes.Split
+		if err = decodeRequiredMetadata(meta, "splits", &splits); err != nil {
+			return nil, err
+		}
+		if err = validateSplits(splits); err != nil {
+			return nil, err
+		}
+		var duration uint64
+		if err = decodeRequiredMetadata(meta, "duration", &duration); err != nil {
+			return nil, err
+		}
+
+		tx = &ttypes.SplitRuleTx{
+			Fee: fee,
+			Initiator: ttypes.TxInput{
+				Address:  initiator,
+				Coins:    coins,
+				Sequence: sequenceOf(initiator),
+			},
+			ResourceID: resourceID,
+			Splits:     splits,
+			Duration:   duration,
+		}
+
+	case SmartContractTx:
+		tx, err = assembleSmartContractTx(ops, meta, sequenceOf)
+
+	case DepositStakeTx, DepositStakeV2Tx:
+		source, coins, err := getSourceCoins(ops, DepositStakeTxSource)
+		if err != nil {
+			return nil, err
+		}
+		holder, err := getAccount(ops, DepositStakeTxHolder, meta, "holder")
+		if err != nil {
+			return nil, err
+		}
+		fee, err := getFee(ops, meta)
+		if err != nil {
+			return nil, err
+		}
+		var purpose uint8
+		if err = decodeRequiredMetadata(meta, "purpose", &purpose); err != nil {
+			return nil, err
+		}
+
+		depositStakeTx := &ttypes.DepositStakeTxV2{
+			Fee:     fee,
+			Purpose: purpose,
+			Source: ttypes.TxInput{
+				Address:  source,
+				Coins:    coins,
+				Sequence: sequenceOf(source),
+			},
+			Holder: ttypes.TxOutput{
+				Address: holder,
+				Coins:   ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(0)},
+			},
+		}
+		if txType == DepositStakeTx {
+			tx = &ttypes.DepositStakeTx{
+				Fee:     depositStakeTx.Fee,
+				Source:  depositStakeTx.Source,
+				Holder:  depositStakeTx.Holder,
+				Purpose: depositStakeTx.Purpose,
+			}
+			break
+		}
+
+		var blsPubkey *bls.PublicKey
+		if ok, err = decodeMetadata(meta, "bls_pub_key", &blsPubkey); err != nil {
+			return nil, err
+		} else if ok {
+			depositStakeTx.BlsPubkey = blsPubkey
+		}
+		var blsPop *bls.Signature
+		if ok, err = decodeMetadata(meta, "bls_pop", &blsPop); err != nil {
+			return nil, err
+		} else if ok {
+			depositStakeTx.BlsPop = blsPop
+		}
+		var holderSig *crypto.Signature
+		if ok, err = decodeMetadata(meta, "holder_sig", &holderSig); err != nil {
+			return nil, err
+		} else if ok {
+			depositStakeTx.HolderSig = holderSig
+		}
+		tx = depositStakeTx
+
+	case WithdrawStakeTx:
+		source, err := getAccount(ops, WithdrawStakeTxSource, nil, "")
+		if err != nil {
+			// the source pays the fee
+			if source, err = getAccount(ops, TxFee, nil, ""); err != nil {
+				return nil, err
+			}
+		}
+		holder, err := getAccount(ops, WithdrawStakeTxHolder, meta, "holder")
+		if err != nil {
+			return nil, err
+		}
+		fee, err := getFee(ops, meta)
+		if err != nil {
+			return nil, err
+		}
+		var purpose uint8
+		if err = decodeRequiredMetadata(meta, "purpose", &purpose); err != nil {
+			return nil, err
+		}
+
+		tx = &ttypes.WithdrawStakeTx{
+			Fee:     fee,
+			Purpose: purpose,
+			Source: ttypes.TxInput{
+				Address:  source,
+				Coins:    ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(0)},
+				Sequence: sequenceOf(source),
+			},
+			Holder: ttypes.TxOutput{
+				Address: holder,
+				Coins:   ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(0)},
+			},
+		}
+
+	case StakeRewardDistributionTx:
+		holder, err := getAccount(ops, StakeRewardDistributionTxHolder, nil, "")
+		if err != nil {
+			// the holder pays the fee
+			if holder, err = getAccount(ops, TxFee, nil, ""); err != nil {
+				return nil, err
+			}
+		}
+		beneficiary, err := getAccount(ops, StakeRewardDistributionTxBeneficiary, meta, "beneficiary")
+		if err != nil {
+			return nil, err
+		}
+		fee, err := getFee(ops, meta)
+		if err != nil {
+			return nil, err
+		}
+		var splitBasisPoint uint
+		if err = decodeRequiredMetadata(meta, "split_basis_point", &splitBasisPoint); err != nil {
+			return nil, err
+		}
+
+		tx = &ttypes.StakeRewardDistributionTx{
+			Fee:             fee,
+			SplitBasisPoint: splitBasisPoint,
+			Holder: ttypes.TxInput{
+				Address:  holder,
+				Coins:    ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(0)},
+				Sequence: sequenceOf(holder),
+			},
+			Beneficiary: ttypes.TxOutput{
+				Address: beneficiary,
+				Coins:   ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(0)},
+			},
+		}
+
+	default:
+		return nil, fmt.Errorf("tx type %v cannot be constructed", txType)
+	}
+	return
+}
+
+// assembleSmartContractTx builds a smart contract call, a TNT-20 token transfer or, when there
+// is no To operation, a contract deployment.
+func assembleSmartContractTx(ops []*types.Operation, meta map[string]interface{}, sequenceOf func(addr cmn.Address) uint64) (ttypes.Tx, error) {
+	var fromOp, toOp *types.Operation
+	for _, op := range ops {
+		switch op.Type {
+		case SmartContractTxFrom.String():
+			if fromOp != nil {
+				return nil, fmt.Errorf("more than one %v operation", SmartContractTxFrom)
+			}
+			fromOp = op
+		case SmartContractTxTo.String():
+			if toOp != nil {
+				return nil, fmt.Errorf("more than one %v operation", SmartContractTxTo)
+			}
+			toOp = op
+		default:
+			return nil, fmt.Errorf("unexpected operation type %v", op.Type)
+		}
+	}
+	if fromOp == nil || fromOp.Account == nil || fromOp.Amount == nil {
+		return nil, fmt.Errorf("missing %v operation", SmartContractTxFrom)
+	}
+	if toOp != nil && (toOp.Account == nil || toOp.Amount == nil) {
+		return nil, fmt.Errorf("invalid %v operation", SmartContractTxTo)
+	}
+
+	fromAddr := cmn.HexToAddress(fromOp.Account.Address)
+	from := ttypes.TxInput{
+		Address:  fromAddr,
+		Coins:    ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(0)},
+		Sequence: sequenceOf(fromAddr),
+	}
+
+	// an empty To address deploys the bytecode in data as a new contract
+	to := ttypes.TxOutput{
+		Coins: ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(0)},
+	}
+
+	gasPrice := big.NewInt(0)
+	if price, ok := meta["gas_price"]; ok {
+		if gasPrice, ok = ToBigInt(price); !ok || gasPrice.Sign() < 0 {
+			return nil, fmt.Errorf("invalid gas price")
+		}
+	}
+
+	gasLimit, ok, err := getMetadataUint64(meta, "gas_limit")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		gasLimit = DefaultContractGasLimit
+	}
+
+	var data []byte
+	if datum, ok := meta["data"]; ok {
+		dataStr, ok := datum.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid data")
+		}
+		if data, err = hex.DecodeString(strings.TrimPrefix(dataStr, "0x")); err != nil {
+			return nil, fmt.Errorf("invalid data: %v", err)
+		}
+	}
+
+	// a TNT-20 transfer calls the token contract, the token amount goes into the calldata
+	if token := GetTokenTransfer(ops); token != nil {
+		if toOp == nil {
+			return nil, fmt.Errorf("missing %v operation for the token transfer", SmartContractTxTo)
+		}
+		fromAmount, ok := new(big.Int).SetString(fromOp.Amount.Value, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid operation amount")
+		}
+		tokenAmount, ok := new(big.Int).SetString(toOp.Amount.Value, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid operation amount")
+		}
+		if tokenAmount.Sign() < 0 || new(big.Int).Add(fromAmount, tokenAmount).Sign() != 0 {
+			return nil, fmt.Errorf("token amounts of the %v and %v operations do not net to zero", SmartContractTxFrom, SmartContractTxTo)
+		}
+		to.Address = cmn.HexToAddress(token.ContractAddress)
+		data = EncodeTnt20Transfer(cmn.HexToAddress(toOp.Account.Address), tokenAmount)
+	} else {
+		fromAmount, isTheta, err := getOpAmount(fromOp)
+		if err != nil {
+			return nil, err
+		}
+		if isTheta {
+			return nil, fmt.Errorf("smart contract tx only transfers TFUEL")
+		}
+		from.Coins.TFuelWei = fromAmount.Neg(fromAmount)
+
+		if toOp != nil {
+			toAmount, isTheta, err := getOpAmount(toOp)
+			if err != nil {
+				return nil, err
+			}
+			if isTheta {
+				return nil, fmt.Errorf("smart contract tx only transfers TFUEL")
+			}
+			to.Address = cmn.HexToAddress(toOp.Account.Address)
+			to.Coins.TFuelWei = toAmount
+		}
+	}
+
+	if toOp == nil && len(data) == 0 {
+		return nil, fmt.Errorf("missing contract bytecode for deployment")
+	}
+
+	return &ttypes.SmartContractTx{
+		From:     from,
+		To:       to,
+		GasLimit: gasLimit,
+		GasPrice: gasPrice,
+		Data:     data,
+	}, nil
+}
+
+// ParseTxForConstruction returns the construction shaped operations and metadata of a tx,
+// the inverse of AssembleTx.
+func ParseTxForConstruction(tx ttypes.Tx) (metadata map[string]interface{}, ops []*types.Operation, err error) {
+	switch tran := tx.(type) {
+	case *ttypes.CoinbaseTx:
+		metadata, ops = ParseCoinbaseTx(*tran, nil, CoinbaseTx)
+	case *ttypes.SlashTx:
+		metadata, ops = ParseSlashTx(*tran, nil, SlashTx)
+	case *ttypes.SendTx:
+		metadata, ops = ParseSendTx(*tran, nil, SendTx)
+	case *ttypes.ReserveFundTx:
+		metadata, ops = ParseReserveFundTx(*tran, nil, ReserveFundTx)
+	case *ttypes.ReleaseFundTx:
+		metadata, ops = ParseReleaseFundTx(*tran, nil, ReleaseFundTx)
+	case *ttypes.ServicePaymentTx:
+		metadata, ops = ParseServicePaymentTx(*tran, nil, ServicePaymentTx)
+	case *ttypes.SplitRuleTx:
+		metadata, ops = ParseSplitRuleTx(*tran, nil, SplitRuleTx)
+	case *ttypes.SmartContractTx:
+		metadata, ops = ParseSmartContractTxForConstruction(*tran, SmartContractTx)
+	case *ttypes.DepositStakeTx:
+		depositStakeTx := ttypes.DepositStakeTxV2{
+			Fee:     tran.Fee,
+			Source:  tran.Source,
+			Holder:  tran.Holder,
+			Purpose: tran.Purpose,
+		}
+		metadata, ops = ParseDepositStakeTx(depositStakeTx, nil, DepositStakeTx)
+		metadata["holder"] = tran.Holder.Address.Hex()
+	case *ttypes.DepositStakeTxV2:
+		metadata, ops = ParseDepositStakeTx(*tran, nil, DepositStakeV2Tx)
+		metadata["holder"] = tran.Holder.Address.Hex()
+	case *ttypes.WithdrawStakeTx:
+		metadata, ops = ParseWithdrawStakeTx(*tran, nil, WithdrawStakeTx)
+		metadata["holder"] = tran.Holder.Address.Hex()
+	case *ttypes.StakeRewardDistributionTx:
+		metadata, ops = ParseStakeRewardDistributionTx(*tran, nil, StakeRewardDistributionTx)
+		metadata["beneficiary"] = tran.Beneficiary.Address.Hex()
+	default:
+		err = fmt.Errorf("unsupported tx type")
+	}
+	return
+}
+
+// ParsePendingTx returns a tx of the mempool, with its operations marked pending. Smart
+// contract txs carry the fee they would pay if they used their whole gas limit.
+func ParsePendingTx(tx ttypes.Tx, txHash string) (*types.Transaction, error) {
+	metadata, ops, err := ParseTxForConstruction(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if smartContractTx, ok := tx.(*ttypes.SmartContractTx); ok && smartContractTx.GasPrice != nil {
+		fee := new(big.Int).Mul(smartContractTx.GasPrice, new(big.Int).SetUint64(smartContractTx.GasLimit))
+		feeOp := &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: int64(len(ops))},
+			Type:                TxFee.String(),
+			Account:             &types.AccountIdentifier{Address: smartContractTx.From.Address.String()},
+			Amount:              &types.Amount{Value: new(big.Int).Neg(fee).String(), Currency: GetTFuelCurrency()},
+		}
+		if len(ops) > 0 {
+			feeOp.RelatedOperations = []*types.OperationIdentifier{{Index: int64(len(ops)) - 1}}
+		}
+		ops = append(ops, feeOp)
+		metadata["fee_estimated"] = true
+	}
+
+	status := BlockStatusPending.String()
+	for _, op := range ops {
+		op.Status = &status
+	}
+
+	return &types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: txHash},
+		Operations:            ops,
+		Metadata:              metadata,
+	}, nil
+}
+
+// SetTxSignature sets the signature of the signer on the tx, returning false if the signer
+// does not sign the tx.
+func SetTxSignature(tx ttypes.Tx, signer cmn.Address, sig *crypto.Signature) bool {
+	switch tran := tx.(type) {
+	case *ttypes.SendTx:
+		return tran.SetSignature(signer, sig)
+	case *ttypes.ReserveFundTx:
+		return tran.SetSignature(signer, sig)
+	case *ttypes.ReleaseFundTx:
+		return tran.SetSignature(signer, sig)
+	case *ttypes.ServicePaymentTx:
+		if signer == tran.Source.Address {
+			tran.SetSourceSignature(sig)
+			return true
+		}
+		if signer == tran.Target.Address {
+			tran.SetTargetSignature(sig)
+			return true
+		}
+		return false
+	case *ttypes.SplitRuleTx:
+		return tran.SetSignature(signer, sig)
+	case *ttypes.SmartContractTx:
+		return tran.SetSignature(signer, sig)
+	case *ttypes.DepositStakeTx:
+		return tran.SetSignature(signer, sig)
+	case *ttypes.DepositStakeTxV2:
+		return tran.SetSignature(signer, sig)
+	case *ttypes.WithdrawStakeTx:
+		return tran.SetSignature(signer, sig)
+	case *ttypes.StakeRewardDistributionTx:
+		return tran.SetSignature(signer, sig)
+	}
+	return false
+}
+
+// validateSplits checks that every split has an address and the percentages sum to at most 100.
+func validateSplits(splits []ttypes.Split) error {
+	total := uint(0)
+	for _, split := range splits {
+		if split.Address == (cmn.Address{}) {
+			return fmt.Errorf("split address is required")
+		}
+		if split.Percentage > 100 || total+split.Percentage > 100 {
+			return fmt.Errorf("split percentages sum to more than 100")
+		}
+		total += split.Percentage
+	}
+	return nil
+}
+
+// getOpAmount returns the amount of the operation and whether it is in THETA or TFUEL.
+func getOpAmount(op *types.Operation) (amount *big.Int, isTheta bool, err error) {
+	if op.Amount == nil || op.Amount.Currency == nil {
+		return nil, false, fmt.Errorf("operation amount is required")
+	}
+	amount, ok := new(big.Int).SetString(op.Amount.Value, 10)
+	if !ok {
+		return nil, false, fmt.Errorf("invalid operation amount")
+	}
+	isTheta = strings.EqualFold(op.Amount.Currency.Symbol, GetThetaCurrency().Symbol)
+	isTFuel := strings.EqualFold(op.Amount.Currency.Symbol, GetTFuelCurrency().Symbol)
+	if !isTheta && !isTFuel {
+		return nil, false, fmt.Errorf("unsupported currency %v", op.Amount.Currency.Symbol)
+	}
+	return amount, isTheta, nil
+}
+
+// collectCoins sums the absolute amounts of the operations of the given type, which must all
+// belong to the same account.
+func collectCoins(ops []*types.Operation, opType TxOpType) (addr cmn.Address, coins ttypes.Coins, found bool, err error) {
+	coins = ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(0)}
+	for _, op := range ops {
+		if op.Type != opType.String() {
+			continue
+		}
+		if op.Account == nil {
+			return addr, coins, found, fmt.Errorf("%v operation account is required", opType)
+		}
+		opAddr := cmn.HexToAddress(op.Account.Address)
+		if found && opAddr != addr {
+			return addr, coins, found, fmt.Errorf("%v operations must belong to one account", opType)
+		}
+		addr, found = opAddr, true
+
+		if op.Amount == nil {
+			continue
+		}
+		amount, isTheta, e := getOpAmount(op)
+		if e != nil {
+			return addr, coins, found, e
+		}
+		amount.Abs(amount)
+		if isTheta {
+			coins.ThetaWei.Add(coins.ThetaWei, amount)
+		} else {
+			coins.TFuelWei.Add(coins.TFuelWei, amount)
+		}
+	}
+	return
+}
+
+// getSourceCoins returns the account and coins of the operations of the given type.
+func getSourceCoins(ops []*types.Operation, opType TxOpType) (cmn.Address, ttypes.Coins, error) {
+	addr, coins, found, err := collectCoins(ops, opType)
+	if err != nil {
+		return addr, coins, err
+	}
+	if !found {
+		return addr, coins, fmt.Errorf("missing %v operation", opType)
+	}
+	return addr, coins, nil
+}
+
+// getFee returns the fee of the TxFee operation, falling back to the fee in the metadata.
+func getFee(ops []*types.Operation, meta map[string]interface{}) (ttypes.Coins, error) {
+	_, coins, found, err := collectCoins(ops, TxFee)
+	if err != nil {
+		return coins, err
+	}
+	if found {
+		if coins.ThetaWei.Sign() != 0 {
+			return coins, fmt.Errorf("fee must be paid in TFUEL")
+		}
+		return coins, nil
+	}
+
+	if fee, ok := meta["fee"]; ok {
+		if coins.TFuelWei, ok = ToBigInt(fee); !ok || coins.TFuelWei.Sign() < 0 {
+			return coins, fmt.Errorf("invalid fee")
+		}
+	}
+	return coins, nil
+}
+
+// getAccount returns the account of the first operation of the given type, falling back to
+// the address under key in the metadata.
+func getAccount(ops []*types.Operation, opType TxOpType, meta map[string]interface{}, key string) (cmn.Address, error) {
+	for _, op := range ops {
+		if op.Type == opType.String() && op.Account != nil {
+			return cmn.HexToAddress(op.Account.Address), nil
+		}
+	}
+	if addr, ok := meta[key].(string); ok && key != "" {
+		if !cmn.IsHexAddress(addr) {
+			return cmn.Address{}, fmt.Errorf("invalid %v address", key)
+		}
+		return cmn.HexToAddress(addr), nil
+	}
+	return cmn.Address{}, fmt.Errorf("missing %v operation", opType)
+}
+
+// getMetadataUint64 returns the uint64 under key in the metadata, and whether it is present.
+func getMetadataUint64(meta map[string]interface{}, key string) (uint64, bool, error) {
+	v, ok := meta[key]
+	if !ok {
+		return 0, false, nil
+	}
+	n, ok := ToUint64(v)
+	if !ok {
+		return 0, true, fmt.Errorf("invalid %v", key)
+	}
+	return n, true, nil
+}
+
+// decodeMetadata decodes the value under key in the metadata into out. Values arrive as
+// generic JSON decoded maps, slices and numbers, so they are re-decoded through JSON.
+func decodeMetadata(meta map[string]interface{}, key string, out interface{}) (bool, error) {
+	v, ok := meta[key]
+	if !ok || v == nil {
+		return false, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return true, fmt.Errorf("invalid %v: %v", key, err)
+	}
+	if err = json.Unmarshal(raw, out); err != nil {
+		return true, fmt.Errorf("invalid %v: %v", key, err)
+	}
+	return true, nil
+}
+
+// decodeRequiredMetadata is decodeMetadata for a value that must be present.
+func decodeRequiredMetadata(meta map[string]interface{}, key string, out interface{}) error {
+	ok, err := decodeMetadata(meta, key, out)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("missing %v", key)
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+package common
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/spf13/viper"
+
+	cmn "github.com/thetatoken/theta/common"
+	ttypes "github.com/thetatoken/theta/ledger/types"
+)
+
+func TestValidateSplits(t *testing.T) {
+	addr1 := cmn.HexToAddress("0x2e833968e5bb786ae419c4d13189fb081cc43bab")
+	addr2 := cmn.HexToAddress("0x9f1233798e905e173560071255140b4a8abd3ec6")
+
+	tests := []struct {
+		name   string
+		splits []ttypes.Split
+		valid  bool
+	}{
+		{"no splits", nil, true},
+		{"single split", []ttypes.Split{{Address: addr1, Percentage: 30}}, true},
+		{"sum to 100", []ttypes.Split{{Address: addr1, Percentage: 60}, {Address: addr2, Percentage: 40}}, true},
+		{"missing address", []ttypes.Split{{Percentage: 10}}, false},
+		{"over 100", []ttypes.Split{{Address: addr1, Percentage: 101}}, false},
+		{"sum over 100", []ttypes.Split{{Address: addr1, Percentage: 60}, {Address: addr2, Percentage: 41}}, false},
+	}
+	for _, test := range tests {
+		if err := validateSplits(test.splits); (err == nil) != test.valid {
+			t.Errorf("%v: expected valid %v, got %v", test.name, test.valid, err)
+		}
+	}
+}
+
+func TestParseSplitRuleTxInitiatorSign(t *testing.T) {
+	tx := ttypes.SplitRuleTx{
+		Fee: ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(1000)},
+		Initiator: ttypes.TxInput{
+			Address:  cmn.HexToAddress("0x2e833968e5bb786ae419c4d13189fb081cc43bab"),
+			Coins:    ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(500)},
+			Sequence: 1,
+		},
+		ResourceID: "rid001",
+	}
+
+	_, ops := ParseSplitRuleTx(tx, nil, SplitRuleTx)
+	if ops[0].Type != SplitRuleTxInitiator.String() || ops[0].Amount.Value != "-500" {
+		t.Errorf("expected a -500 initiator operation, got %v %v", ops[0].Type, ops[0].Amount.Value)
+	}
+}
+
+func testOp(index int64, opType TxOpType, addr cmn.Address, value string, currency *types.Currency) *types.Operation {
+	return &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{Index: index},
+		Type:                opType.String(),
+		Account:             &types.AccountIdentifier{Address: addr.Hex()},
+		Amount:              &types.Amount{Value: value, Currency: currency},
+	}
+}
+
+// TestAssembleParseRoundTrip checks that the operations and metadata parsed from an assembled
+// tx assemble back into the same tx, for every tx type the construction API builds.
+func TestAssembleParseRoundTrip(t *testing.T) {
+	addr1 := cmn.HexToAddress("0x2e833968e5bb786ae419c4d13189fb081cc43bab")
+	addr2 := cmn.HexToAddress("0x9f1233798e905e173560071255140b4a8abd3ec6")
+	tokenAddr := cmn.HexToAddress("0x4f8a4bd7a4b1f0cd9c8ab2ec5e0f8cd1e4ef6a77")
+
+	viper.Set(CfgRosettaTokens, []map[string]interface{}{
+		{"symbol": "TKN", "decimals": 18, "contractAddress": tokenAddr.Hex()},
+	})
+	defer viper.Set(CfgRosettaTokens, nil)
+	token := GetTokenCurrency(GetTokenByContract(tokenAddr.Hex()))
+
+	theta, tfuel := GetThetaCurrency(), GetTFuelCurrency()
+	fee := "-300000000000000000"
+
+	tests := []struct {
+		name string
+		ops  []*types.Operation
+		meta map[string]interface{}
+	}{
+		{
+			"SendTx",
+			[]*types.Operation{
+				testOp(0, SendTxInput, addr1, "-100", theta),
+				testOp(1, SendTxInput, addr1, "-200", tfuel),
+				testOp(2, SendTxOutput, addr2, "100", theta),
+				testOp(3, SendTxOutput, addr2, "200", tfuel),
+				testOp(4, TxFee, addr1, fee, tfuel),
+			},
+			map[string]interface{}{"type": SendTx, "sequence": uint64(3)},
+		},
+		{
+			"ReserveFundTx",
+			[]*types.Operation{
+				testOp(0, ReserveFundTxSource, addr1, "-500", tfuel),
+				testOp(1, TxFee, addr1, fee, tfuel),
+			},
+			map[string]interface{}{
+				"type":         ReserveFundTx,
+				"sequence":     uint64(3),
+				"collateral":   ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(600)},
+				"resource_ids": []string{"rid001"},
+				"duration":     uint64(100),
+			},
+		},
+		{
+			"ReleaseFundTx",
+			[]*types.Operation{
+				testOp(0, ReleaseFundTxSource, addr1, "500", tfuel),
+				testOp(1, TxFee, addr1, fee, tfuel),
+			},
+			map[string]interface{}{"type": ReleaseFundTx, "sequence": uint64(3), "reserve_sequence": uint64(2)},
+		},
+		{
+			"ServicePaymentTx",
+			[]*types.Operation{
+				testOp(0, ServicePaymentTxSource, addr1, "-1000", tfuel),
+				testOp(1, ServicePaymentTxTarget, addr2, "1000", tfuel),
+				testOp(2, TxFee, addr2, fee, tfuel),
+			},
+			map[string]interface{}{
+				"type":             ServicePaymentTx,
+				"sequence":         uint64(3),
+				"sequences":        map[string]uint64{addr1.Hex(): 5, addr2.Hex(): 7},
+				"payment_sequence": uint64(1),
+				"reserve_sequence": uint64(2),
+				"resource_id":      "rid001",
+			},
+		},
+		{
+			"SplitRuleTx",
+			[]*types.Operation{
+				testOp(0, SplitRuleTxInitiator, addr1, "-500", tfuel),
+				testOp(1, TxFee, addr1, fee, tfuel),
+			},
+			map[string]interface{}{
+				"type":        SplitRuleTx,
+				"sequence":    uint64(3),
+				"resource_id": "rid001",
+				"splits":      []ttypes.Split{{Address: addr2, Percentage: 30}},
+				"duration":    uint64(100),
+			},
+		},
+		{
+			"SmartContractTx call",
+			[]*types.Operation{
+				testOp(0, SmartContractTxFrom, addr1, "-100", tfuel),
+				testOp(1, SmartContractTxTo, addr2, "100", tfuel),
+			},
+			map[string]interface{}{
+				"type":      SmartContractTx,
+				"sequence":  uint64(3),
+				"gas_limit": uint64(50000),
+				"gas_price": big.NewInt(4000000000000),
+				"data":      "0x12345678",
+			},
+		},
+		{
+			"SmartContractTx deployment",
+			[]*types.Operation{
+				testOp(0, SmartContractTxFrom, addr1, "0", tfuel),
+			},
+			map[string]interface{}{
+				"type":      SmartContractTx,
+				"sequence":  uint64(3),
+				"gas_limit": uint64(500000),
+				"gas_price": big.NewInt(4000000000000),
+				"data":      "6080604052",
+			},
+		},
+		{
+			"SmartContractTx token transfer",
+			[]*types.Operation{
+				testOp(0, SmartContractTxFrom, addr1, "-100", token),
+				testOp(1, SmartContractTxTo, addr2, "100", token),
+			},
+			map[string]interface{}{
+				"type":      SmartContractTx,
+				"sequence":  uint64(3),
+				"gas_limit": uint64(50000),
+				"gas_price": big.NewInt(4000000000000),
+			},
+		},
+		{
+			"DepositStakeTx",
+			[]*types.Operation{
+				testOp(0, DepositStakeTxSource, addr1, "-1000", theta),
+				testOp(1, TxFee, addr1, fee, tfuel),
+			},
+			map[string]interface{}{"type": DepositStakeTx, "sequence": uint64(3), "holder": addr2.Hex(), "purpose": uint8(0)},
+		},
+		{
+			"WithdrawStakeTx",
+			[]*types.Operation{
+				testOp(0, TxFee, addr1, fee, tfuel),
+			},
+			map[string]interface{}{"type": WithdrawStakeTx, "sequence": uint64(3), "holder": addr2.Hex(), "purpose": uint8(0)},
+		},
+		{
+			"StakeRewardDistributionTx",
+			[]*types.Operation{
+				testOp(0, TxFee, addr1, fee, tfuel),
+			},
+			map[string]interface{}{"type": StakeRewardDistributionTx, "sequence": uint64(3), "beneficiary": addr2.Hex(), "split_basis_point": uint(100)},
+		},
+	}
+
+	for _, test := range tests {
+		tx, err := AssembleTx(test.ops, test.meta)
+		if err != nil {
+			t.Errorf("%v: failed to assemble: %v", test.name, err)
+			continue
+		}
+		raw, err := ttypes.TxToBytes(tx)
+		if err != nil {
+			t.Errorf("%v: failed to encode: %v", test.name, err)
+			continue
+		}
+		decoded, err := ttypes.TxFromBytes(raw)
+		if err != nil {
+			t.Errorf("%v: failed to decode: %v", test.name, err)
+			continue
+		}
+
+		metadata, ops, err := ParseTxForConstruction(decoded)
+		if err != nil {
+			t.Errorf("%v: failed to parse: %v", test.name, err)
+			continue
+		}
+
+		// the sequences come from /construction/metadata, not from the parsed tx
+		meta := make(map[string]interface{})
+		for key, v := range test.meta {
+			meta[key] = v
+		}
+		for key, v := range metadata {
+			meta[key] = v
+		}
+		reassembled, err := AssembleTx(ops, meta)
+		if err != nil {
+			t.Errorf("%v: failed to assemble the parsed tx: %v", test.name, err)
+			continue
+		}
+		reencoded, err := ttypes.TxToBytes(reassembled)
+		if err != nil {
+			t.Errorf("%v: failed to encode the parsed tx: %v", test.name, err)
+			continue
+		}
+		if !bytes.Equal(raw, reencoded) {
+			t.Errorf("%v: parsed tx does not assemble back into the same tx", test.name)
+		}
+	}
+}
+
+func TestAssembleTokenTransfer(t *testing.T) {
+	addr1 := cmn.HexToAddress("0x2e833968e5bb786ae419c4d13189fb081cc43bab")
+	addr2 := cmn.HexToAddress("0x9f1233798e905e173560071255140b4a8abd3ec6")
+	tokenAddr := cmn.HexToAddress("0x4f8a4bd7a4b1f0cd9c8ab2ec5e0f8cd1e4ef6a77")
+
+	viper.Set(CfgRosettaTokens, []map[string]interface{}{
+		{"symbol": "TKN", "decimals": 18, "contractAddress": tokenAddr.Hex()},
+	})
+	defer viper.Set(CfgRosettaTokens, nil)
+	token := GetTokenCurrency(GetTokenByContract(tokenAddr.Hex()))
+
+	tests := []struct {
+		name  string
+		ops   []*types.Operation
+		valid bool
+	}{
+		{"transfer", []*types.Operation{testOp(0, SmartContractTxFrom, addr1, "-100", token), testOp(1, SmartContractTxTo, addr2, "100", token)}, true},
+		{"two from operations", []*types.Operation{testOp(0, SmartContractTxFrom, addr1, "-100", token), testOp(1, SmartContractTxFrom, addr2, "-100", token)}, false},
+		{"two to operations", []*types.Operation{testOp(0, SmartContractTxTo, addr1, "100", token), testOp(1, SmartContractTxTo, addr2, "100", token)}, false},
+		{"from only", []*types.Operation{testOp(0, SmartContractTxFrom, addr1, "-100", token)}, false},
+		{"amounts not netting", []*types.Operation{testOp(0, SmartContractTxFrom, addr1, "-100", token), testOp(1, SmartContractTxTo, addr2, "200", token)}, false},
+		{"reversed amounts", []*types.Operation{testOp(0, SmartContractTxFrom, addr1, "100", token), testOp(1, SmartContractTxTo, addr2, "-100", token)}, false},
+	}
+
+	meta := map[string]interface{}{"type": SmartContractTx, "sequence": uint64(3)}
+	for _, test := range tests {
+		if GetTokenTransfer(test.ops) == nil && len(test.ops) == 2 {
+			t.Errorf("%v: expected a token transfer", test.name)
+		}
+		tx, err := AssembleTx(test.ops, meta)
+		if (err == nil) != test.valid {
+			t.Errorf("%v: expected valid %v, got %v", test.name, test.valid, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		smartContractTx := tx.(*ttypes.SmartContractTx)
+		if smartContractTx.To.Address != tokenAddr || !bytes.Equal(smartContractTx.Data, EncodeTnt20Transfer(addr2, big.NewInt(100))) {
+			t.Errorf("%v: unexpected token transfer %v", test.name, smartContractTx)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package common
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/spf13/viper"
+
+	"github.com/thetatoken/theta/blockchain"
+	cmn "github.com/thetatoken/theta/common"
+	"github.com/thetatoken/theta/crypto"
+)
+
+type crossChainDirection int
+
+const (
+	crossChainOut crossChainDirection = iota
+	crossChainIn
+)
+
+// crossChainEvent describes an event emitted by a Metachain token bank. All the event
+// parameters are non indexed, args lists them as "name:type" in the order of the data words.
+type crossChainEvent struct {
+	name      string
+	direction crossChainDirection
+	tfuel     bool // TFuel moves as native balance, TNT-20 as token balance
+	args      []string
+	account   string // arg holding the account whose balance changes
+	amount    string
+	nonce     string // arg holding the nonce matching both legs of the transfer
+	receiver  string // arg holding the receiver on the counterpart chain, for outgoing transfers
+	targetID  string // arg holding the counterpart chain ID, if any
+	viaDenom  bool   // the counterpart is the origin chain of the token, found in the denom
+}
+
+var crossChainEvents = map[cmn.Hash]crossChainEvent{}
+
+func init() {
+	for _, event := range []crossChainEvent{
+		{
+			name:      "TFuelTokenLocked",
+			direction: crossChainOut,
+			tfuel:     true,
+			args:      []string{"denom:string", "sourceChainTokenSender:address", "targetChainID:uint256", "targetChainVoucherReceiver:address", "lockedAmount:uint256", "tokenLockNonce:uint256"},
+			account:   "sourceChainTokenSender",
+			amount:    "lockedAmount",
+			nonce:     "tokenLockNonce",
+			receiver:  "targetChainVoucherReceiver",
+			targetID:  "targetChainID",
+		},
+		{
+			name:      "TFuelVoucherMinted",
+			direction: crossChainIn,
+			tfuel:     true,
+			args:      []string{"denom:string", "targetChainVoucherReceiver:address", "mintedAmount:uint256", "sourceChainTokenLockNonce:uint256", "voucherMintNonce:uint256"},
+			account:   "targetChainVoucherReceiver",
+			amount:    "mintedAmount",
+			nonce:     "sourceChainTokenLockNonce",
+			viaDenom:  true,
+		},
+		{
+			name:      "TFuelVoucherBurned",
+			direction: crossChainOut,
+			tfuel:     true,
+			args:      []string{"denom:string", "sourceChainVoucherOwner:address", "targetChainTokenReceiver:address", "burnedAmount:uint256", "voucherBurnNonce:uint256"},
+			account:   "sourceChainVoucherOwner",
+			amount:    "burnedAmount",
+			nonce:     "voucherBurnNonce",
+			receiver:  "targetChainTokenReceiver",
+			viaDenom:  true,
+		},
+		{
+			name:      "TFuelTokenUnlocked",
+			direction: crossChainIn,
+			tfuel:     true,
+			args:      []string{"denom:string", "targetChainTokenReceiver:address", "unlockedAmount:uint256", "sourceChainVoucherBurnNonce:uint256", "tokenUnlockNonce:uint256"},
+			account:   "targetChainTokenReceiver",
+			amount:    "unlockedAmount",
+			nonce:     "sourceChainVoucherBurnNonce",
+		},
+		{
+			name:      "TNT20TokenLocked",
+			direction: crossChainOut,
+			args:      []string{"denom:string", "sourceChainTokenSender:address", "targetChainID:uint256", "targetChainVoucherReceiver:address", "lockedAmount:uint256", "name:string", "symbol:string", "decimals:uint8", "tokenLockNonce:uint256"},
+			account:   "sourceChainTokenSender",
+			amount:    "lockedAmount",
+			nonce:     "tokenLockNonce",
+			receiver:  "targetChainVoucherReceiver",
+			targetID:  "targetChainID",
+		},
+		{
+			name:      "TNT20VoucherMinted",
+			direction: crossChainIn,
+			args:      []string{"denom:string", "targetChainVoucherReceiver:address", "voucherContract:address", "mintedAmount:uint256", "sourceChainTokenLockNonce:uint256", "voucherMintNonce:uint256"},
+			account:   "targetChainVoucherReceiver",
+			amount:    "mintedAmount",
+			nonce:     "sourceChainTokenLockNonce",
+			viaDenom:  true,
+		},
+		{
+			name:      "TNT20VoucherBurned",
+			direction: crossChainOut,
+			args:      []string{"denom:string", "sourceChainVoucherOwner:address", "targetChainTokenReceiver:address", "burnedAmount:uint256", "voucherBurnNonce:uint256"},
+			account:   "sourceChainVoucherOwner",
+			amount:    "burnedAmount",
+			nonce:     "voucherBurnNonce",
+			receiver:  "targetChainTokenReceiver",
+			viaDenom:  true,
+		},
+		{
+			name:      "TNT20TokenUnlocked",
+			direction: crossChainIn,
+			args:      []string{"denom:string", "targetChainTokenReceiver:address", "unlockedAmount:uint256", "sourceChainVoucherBurnNonce:uint256", "tokenUnlockNonce:uint256"},
+			account:   "targetChainTokenReceiver",
+			amount:    "unlockedAmount",
+			nonce:     "sourceChainVoucherBurnNonce",
+		},
+	} {
+		var argTypes []string
+		for _, arg := range event.args {
+			argTypes = append(argTypes, arg[strings.Index(arg, ":")+1:])
+		}
+		signature := event.name + "(" + strings.Join(argTypes, ",") + ")"
+		crossChainEvents[crypto.Keccak256Hash([]byte(signature))] = event
+	}
+}
+
+// isTokenBank checks whether the address is one of the Metachain token banks under rosetta.tokenBanks
+func isTokenBank(addr cmn.Address) bool {
+	for _, bank := range viper.GetStringSlice(CfgRosettaTokenBanks) {
+		if strings.EqualFold(bank, addr.Hex()) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseCrossChainTransfers turns the operations of a tx calling a Metachain token bank into
+// CrossChainTransferOut/In operations. Only the transfers of the events the token banks emit
+// are recognised, the bridge contract calls themselves are not decoded, so a call to a token
+// bank emitting none of these events is left as a plain contract call. A TFuel transfer
+// retypes the matching balance change operation, a TNT-20 transfer, whose token balance is
+// not tracked, adds an operation without amount. The metadata carries the denom, the
+// counterpart chain ID when known, and the nonce shared by both legs of the transfer.
+func ParseCrossChainTransfers(transaction *types.Transaction, receipt *blockchain.TxReceiptEntry, status *string) {
+	if receipt == nil {
+		return
+	}
+
+	for _, log := range receipt.Logs {
+		if log == nil || len(log.Topics) == 0 || !isTokenBank(log.Address) {
+			continue
+		}
+		event, ok := crossChainEvents[log.Topics[0]]
+		if !ok {
+			continue
+		}
+		args, ok := decodeEventData(event.args, log.Data)
+		if !ok {
+			logger.Warnf("Failed to decode %v event of tx %v", event.name, transaction.TransactionIdentifier.Hash)
+			continue
+		}
+
+		account := args[event.account].(cmn.Address)
+		amount := args[event.amount].(*big.Int)
+		denom := args["denom"].(string)
+
+		opType := CrossChainTransferOut
+		value := new(big.Int).Neg(amount)
+		if event.direction == crossChainIn {
+			opType = CrossChainTransferIn
+			value = amount
+		}
+
+		metadata := map[string]interface{}{
+			"event":  event.name,
+			"denom":  denom,
+			"nonce":  args[event.nonce].(*big.Int).String(),
+			"amount": amount.String(),
+		}
+		if event.targetID != "" {
+			metadata["counterpart_chain_id"] = args[event.targetID].(*big.Int).String()
+		} else if chainID := denomChainID(denom); event.viaDenom && chainID != "" {
+			metadata["counterpart_chain_id"] = chainID
+		}
+		if event.receiver != "" {
+			metadata["receiver"] = args[event.receiver].(cmn.Address).Hex()
+		}
+
+		var op *types.Operation
+		if event.tfuel {
+			op = findBalanceOp(transaction.Operations, account, value)
+		}
+		if op == nil {
+			op = &types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{Index: int64(len(transaction.Operations))},
+				Account:             &types.AccountIdentifier{Address: account.String()},
+				Status:              status,
+			}
+			transaction.Operations = append(transaction.Operations, op)
+		}
+		op.Type = opType.String()
+		op.Metadata = metadata
+	}
+}
+
+// findBalanceOp returns the smart contract operation moving the TFuel value for the account.
+func findBalanceOp(ops []*types.Operation, account cmn.Address, value *big.Int) *types.Operation {
+	for _, op := range ops {
+		if op.Type != SmartContractTxFrom.String() && op.Type != SmartContractTxTo.String() {
+			continue
+		}
+		if op.Account == nil || op.Amount == nil || op.Amount.Currency.Symbol != GetTFuelCurrency().Symbol {
+			continue
+		}
+		if strings.EqualFold(op.Account.Address, account.String()) && op.Amount.Value == value.String() {
+			return op
+		}
+	}
+	return nil
+}
+
+// denomChainID returns the origin chain ID a denom starts with, e.g. "360777" for "360777/tfuel".
+func denomChainID(denom string) string {
+	chainID := strings.SplitN(denom, "/", 2)[0]
+	if _, err := strconv.ParseUint(chainID, 10, 64); err != nil {
+		return ""
+	}
+	return chainID
+}
+
+// decodeEventData decodes the ABI encoded event data into the named args.
+func decodeEventData(args []string, data []byte) (map[string]interface{}, bool) {
+	word := func(offset uint64) ([]byte, bool) {
+		if offset > uint64(len(data)) || uint64(len(data))-offset < abiWordSize {
+			return nil, false
+		}
+		return data[offset : offset+abiWordSize], true
+	}
+
+	values := make(map[string]interface{})
+	for i, arg := range args {
+		sep := strings.Index(arg, ":")
+		name, typ := arg[:sep], arg[sep+1:]
+
+		w, ok := word(uint64(i) * abiWordSize)
+		if !ok {
+			return nil, false
+		}
+		switch typ {
+		case "address":
+			values[name] = cmn.BytesToAddress(w[abiWordSize-cmn.AddressLength:])
+		case "string":
+			offset := new(big.Int).SetBytes(w)
+			if !offset.IsUint64() || offset.Uint64()%abiWordSize != 0 {
+				return nil, false
+			}
+			lw, ok := word(offset.Uint64())
+			if !ok {
+				return nil, false
+			}
+			length := new(big.Int).SetBytes(lw)
+			start := offset.Uint64() + abiWordSize
+			// start is within data once the length word is read, comparing against the bytes
+			// left does not overflow like start+length would
+			if !length.IsUint64() || length.Uint64() > uint64(len(data))-start {
+				return nil, false
+			}
+			values[name] = string(data[start : start+length.Uint64()])
+		default:
+			values[name] = new(big.Int).SetBytes(w)
+		}
+	}
+	return values, true
+}
@@ -0,0 +1,72 @@
+package common
+
+import (
+	"math/big"
+	"testing"
+
+	cmn "github.com/thetatoken/theta/common"
+)
+
+func abiWord(v *big.Int) []byte {
+	w := make([]byte, abiWordSize)
+	b := v.Bytes()
+	copy(w[abiWordSize-len(b):], b)
+	return w
+}
+
+// encodeEventData encodes (string, address, uint256) args, with the string offset and length
+// words given so that malformed data can be built.
+func encodeEventData(offset, length *big.Int, str string, addr cmn.Address, amount *big.Int) []byte {
+	data := abiWord(offset)
+	data = append(data, abiWord(new(big.Int).SetBytes(addr.Bytes()))...)
+	data = append(data, abiWord(amount)...)
+	data = append(data, abiWord(length)...)
+	padded := make([]byte, (len(str)+abiWordSize-1)/abiWordSize*abiWordSize)
+	copy(padded, str)
+	return append(data, padded...)
+}
+
+func TestDecodeEventData(t *testing.T) {
+	args := []string{"denom:string", "sender:address", "amount:uint256"}
+	addr := cmn.HexToAddress("0x2e833968e5bb786ae419c4d13189fb081cc43bab")
+	amount := big.NewInt(12345)
+	maxUint64 := new(big.Int).SetUint64(^uint64(0))
+
+	valid := encodeEventData(big.NewInt(96), big.NewInt(12), "360777/tfuel", addr, amount)
+
+	tests := []struct {
+		name  string
+		data  []byte
+		valid bool
+	}{
+		{"valid", valid, true},
+		{"empty", nil, false},
+		{"truncated head", valid[:2*abiWordSize], false},
+		{"truncated string", valid[:4*abiWordSize], false},
+		{"unaligned offset", encodeEventData(big.NewInt(95), big.NewInt(12), "360777/tfuel", addr, amount), false},
+		{"offset past data", encodeEventData(big.NewInt(320), big.NewInt(12), "360777/tfuel", addr, amount), false},
+		{"huge offset", encodeEventData(new(big.Int).Lsh(big.NewInt(1), 63), big.NewInt(12), "360777/tfuel", addr, amount), false},
+		{"offset over uint64", encodeEventData(new(big.Int).Lsh(big.NewInt(1), 64), big.NewInt(12), "360777/tfuel", addr, amount), false},
+		{"length past data", encodeEventData(big.NewInt(96), big.NewInt(33), "360777/tfuel", addr, amount), false},
+		{"length overflowing the end", encodeEventData(big.NewInt(96), maxUint64, "360777/tfuel", addr, amount), false},
+	}
+	for _, test := range tests {
+		values, ok := decodeEventData(args, test.data)
+		if ok != test.valid {
+			t.Errorf("%v: expected valid %v, got %v", test.name, test.valid, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if values["denom"] != "360777/tfuel" {
+			t.Errorf("%v: unexpected denom %v", test.name, values["denom"])
+		}
+		if values["sender"] != addr {
+			t.Errorf("%v: unexpected sender %v", test.name, values["sender"])
+		}
+		if values["amount"].(*big.Int).Cmp(amount) != 0 {
+			t.Errorf("%v: unexpected amount %v", test.name, values["amount"])
+		}
+	}
+}
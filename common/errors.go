@@ -199,6 +199,66 @@ var (
 		Message: "db key not found",
 	}
 
+	ErrInvalidTxSequence = &types.Error{
+		Code:      34,
+		Message:   "invalid transaction sequence",
+		Retriable: true,
+	}
+
+	ErrInsufficientBalance = &types.Error{
+		Code:      35,
+		Message:   "insufficient balance",
+		Retriable: false,
+	}
+
+	ErrInvalidSignature = &types.Error{
+		Code:      36,
+		Message:   "invalid transaction signature",
+		Retriable: false,
+	}
+
+	ErrInsufficientFee = &types.Error{
+		Code:      37,
+		Message:   "insufficient transaction fee",
+		Retriable: false,
+	}
+
+	ErrDuplicateTx = &types.Error{
+		Code:      38,
+		Message:   "transaction already submitted",
+		Retriable: false,
+	}
+
+	ErrTxAbandoned = &types.Error{
+		Code:      39,
+		Message:   "transaction abandoned by the node",
+		Retriable: false,
+	}
+
+	ErrSubmitTimeout = &types.Error{
+		Code:      40,
+		Message:   "timed out waiting for transaction",
+		Retriable: true,
+	}
+
+	ErrTxHashMismatch = &types.Error{
+		Code:      41,
+		Message:   "transaction hash mismatch",
+		Retriable: false,
+	}
+
+	ErrMempoolFilterUnavailable = &types.Error{
+		Code:      42,
+		Message:   "mempool filter cannot be applied to pending transactions the node does not report",
+		Retriable: false,
+	}
+
+	ErrUnableToCall = &types.Error{
+		Code:      43,
+		Message:   "unable to call: ",
+		Retriable: true,
+	}
+
 	ErrorList = []*types.Error{
 		ErrUnableToGetChainID,
 		ErrInvalidBlockchain,
@@ -233,5 +293,22 @@ var (
 		ErrUnableToGetMemPoolTx,
 		ErrUnavailableOffline,
 		ErrDBKeyNotFound,
+		ErrInvalidTxSequence,
+		ErrInsufficientBalance,
+		ErrInvalidSignature,
+		ErrInsufficientFee,
+		ErrDuplicateTx,
+		ErrTxAbandoned,
+		ErrSubmitTimeout,
+		ErrTxHashMismatch,
+		ErrMempoolFilterUnavailable,
+		ErrUnableToCall,
 	}
 )
+
+// CopyError returns a copy of the shared error, so that detail can be added to its message
+// without changing the error for every other request.
+func CopyError(terr *types.Error) *types.Error {
+	copied := *terr
+	return &copied
+}
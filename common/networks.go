@@ -0,0 +1,88 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+// NetworkConfig describes a Theta network served by the adaptor
+type NetworkConfig struct {
+	ChainID     string           `mapstructure:"chainID"` // optional, the chain ID reported by the node is used if empty
+	RPCEndpoint string           `mapstructure:"rpcEndpoint"`
+	DataPath    string           `mapstructure:"dataPath"` // directory of the return stakes DB of the network
+	Subchains   []SubchainConfig `mapstructure:"subchains"`
+}
+
+// SubchainConfig describes a Metachain subchain of a network, served as a Rosetta sub-network
+type SubchainConfig struct {
+	ChainID     string  `mapstructure:"chainID"`
+	RPCEndpoint string  `mapstructure:"rpcEndpoint"`
+	DataPath    string  `mapstructure:"dataPath"` // defaults to the <chainID> directory under the network data path
+	Tokens      []Token `mapstructure:"tokens"`   // TNT-20 tokens of the subchain, rosetta.tokens only applies to the main chains
+}
+
+// GetNetworkConfigs returns the networks configured under rosetta.networks, or the single
+// network of theta.rpcEndpoint if none is configured. A network without a dataPath gets the
+// <chainID> directory under data.path, and a single network without a chainID data.path
+// itself.
+func GetNetworkConfigs() ([]NetworkConfig, error) {
+	var networks []NetworkConfig
+	if err := viper.UnmarshalKey(CfgRosettaNetworks, &networks); err != nil {
+		logger.Errorf("Failed to parse network config: %v", err)
+	}
+	if len(networks) == 0 {
+		network := NetworkConfig{RPCEndpoint: GetThetaRPCEndpoint()}
+		if err := viper.UnmarshalKey(CfgRosettaSubchains, &network.Subchains); err != nil {
+			logger.Errorf("Failed to parse subchain config: %v", err)
+		}
+		networks = []NetworkConfig{network}
+	}
+
+	// each network and subchain keeps its own return stakes DB
+	dataPaths := make(map[string]string)
+	checkDataPath := func(chainID, dataPath string) error {
+		dataPath = filepath.Clean(dataPath)
+		if other, ok := dataPaths[dataPath]; ok {
+			return fmt.Errorf("networks %v and %v share the data path %v", other, chainID, dataPath)
+		}
+		dataPaths[dataPath] = chainID
+		return nil
+	}
+
+	for i := range networks {
+		if networks[i].RPCEndpoint == "" {
+			networks[i].RPCEndpoint = GetThetaRPCEndpoint()
+		}
+		if networks[i].DataPath == "" {
+			if networks[i].ChainID != "" {
+				networks[i].DataPath = filepath.Join(viper.GetString(CfgDataPath), networks[i].ChainID)
+			} else if len(networks) == 1 {
+				networks[i].DataPath = viper.GetString(CfgDataPath)
+			} else {
+				return nil, fmt.Errorf("network %v needs a chainID or a dataPath", networks[i].RPCEndpoint)
+			}
+		}
+		if err := checkDataPath(networks[i].ChainID, networks[i].DataPath); err != nil {
+			return nil, err
+		}
+
+		var subchains []SubchainConfig
+		for _, subchain := range networks[i].Subchains {
+			if subchain.ChainID == "" || subchain.RPCEndpoint == "" {
+				logger.Errorf("Ignoring subchain %v without chainID or rpcEndpoint", subchain)
+				continue
+			}
+			if subchain.DataPath == "" {
+				subchain.DataPath = filepath.Join(networks[i].DataPath, subchain.ChainID)
+			}
+			if err := checkDataPath(subchain.ChainID, subchain.DataPath); err != nil {
+				return nil, err
+			}
+			subchains = append(subchains, subchain)
+		}
+		networks[i].Subchains = subchains
+	}
+	return networks, nil
+}
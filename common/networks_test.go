@@ -0,0 +1,101 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestGetNetworkConfigsDataPaths(t *testing.T) {
+	viper.Set(CfgDataPath, "/data")
+	defer viper.Set(CfgDataPath, nil)
+
+	subchain := map[string]interface{}{"chainID": "tsub360777", "rpcEndpoint": "http://127.0.0.1:16900/rpc"}
+
+	tests := []struct {
+		name      string
+		networks  []map[string]interface{}
+		dataPaths []string // of the networks, each followed by its subchains
+		valid     bool
+	}{
+		{
+			"single network without chain ID",
+			[]map[string]interface{}{{"rpcEndpoint": "http://127.0.0.1:16888/rpc"}},
+			[]string{"/data"},
+			true,
+		},
+		{
+			"default under chain ID",
+			[]map[string]interface{}{
+				{"chainID": "mainnet", "subchains": []map[string]interface{}{subchain}},
+				{"chainID": "testnet"},
+			},
+			[]string{"/data/mainnet", "/data/mainnet/tsub360777", "/data/testnet"},
+			true,
+		},
+		{
+			"configured data path",
+			[]map[string]interface{}{
+				{"chainID": "mainnet", "dataPath": "/mainnet"},
+				{"dataPath": "/testnet"},
+			},
+			[]string{"/mainnet", "/testnet"},
+			true,
+		},
+		{
+			"several networks without chain ID",
+			[]map[string]interface{}{{"chainID": "mainnet"}, {"rpcEndpoint": "http://127.0.0.1:16889/rpc"}},
+			nil,
+			false,
+		},
+		{
+			"shared data path",
+			[]map[string]interface{}{
+				{"chainID": "mainnet", "dataPath": "/data/shared"},
+				{"chainID": "testnet", "dataPath": "/data/shared/"},
+			},
+			nil,
+			false,
+		},
+		{
+			"subchain sharing a network data path",
+			[]map[string]interface{}{
+				{"chainID": "mainnet", "subchains": []map[string]interface{}{subchain}},
+				{"chainID": "testnet", "dataPath": "/data/mainnet/tsub360777"},
+			},
+			nil,
+			false,
+		},
+	}
+
+	for _, test := range tests {
+		viper.Set(CfgRosettaNetworks, test.networks)
+		networks, err := GetNetworkConfigs()
+		if (err == nil) != test.valid {
+			t.Errorf("%v: expected valid %v, got %v", test.name, test.valid, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		var dataPaths []string
+		for _, network := range networks {
+			dataPaths = append(dataPaths, network.DataPath)
+			for _, subchain := range network.Subchains {
+				dataPaths = append(dataPaths, subchain.DataPath)
+			}
+		}
+		if len(dataPaths) != len(test.dataPaths) {
+			t.Errorf("%v: expected data paths %v, got %v", test.name, test.dataPaths, dataPaths)
+			continue
+		}
+		for i := range dataPaths {
+			if dataPaths[i] != test.dataPaths[i] {
+				t.Errorf("%v: expected data paths %v, got %v", test.name, test.dataPaths, dataPaths)
+				break
+			}
+		}
+	}
+	viper.Set(CfgRosettaNetworks, nil)
+}
@@ -0,0 +1,136 @@
+package common
+
+import (
+	"sync"
+	"time"
+
+	cmn "github.com/thetatoken/theta/common"
+)
+
+type nonceReservation struct {
+	TxHash    string // empty while the tx is built but not submitted yet
+	ExpiresAt time.Time
+}
+
+// NonceTracker keeps track of the sequences handed out to txs built or submitted through the
+// adaptor that have not been committed yet, so that txs built back-to-back from the same account
+// get distinct sequences. A sequence handed out by /construction/metadata is held for buildTTL,
+// the sequence of a submitted tx for ttl.
+type NonceTracker struct {
+	mu           sync.Mutex
+	ttl          time.Duration
+	buildTTL     time.Duration
+	reservations map[cmn.Address]map[uint64]*nonceReservation
+}
+
+func NewNonceTracker(ttl time.Duration, buildTTL time.Duration) *NonceTracker {
+	return &NonceTracker{
+		ttl:          ttl,
+		buildTTL:     buildTTL,
+		reservations: make(map[cmn.Address]map[uint64]*nonceReservation),
+	}
+}
+
+// Reserve records the sequence used by a submitted tx, replacing the reservation made when
+// the tx was built.
+func (nt *NonceTracker) Reserve(addr cmn.Address, sequence uint64, txHash string) {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+
+	if _, ok := nt.reservations[addr]; !ok {
+		nt.reservations[addr] = make(map[uint64]*nonceReservation)
+	}
+	nt.reservations[addr][sequence] = &nonceReservation{
+		TxHash:    txHash,
+		ExpiresAt: time.Now().Add(nt.ttl),
+	}
+}
+
+// Release drops the reservations of a tx that was rejected or dropped by the node.
+func (nt *NonceTracker) Release(txHash string) {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+
+	for addr, reservations := range nt.reservations {
+		for seq, reservation := range reservations {
+			if reservation.TxHash == txHash {
+				delete(reservations, seq)
+			}
+		}
+		if len(reservations) == 0 {
+			delete(nt.reservations, addr)
+		}
+	}
+}
+
+// PendingTxHashes returns the hashes of the txs still holding a reservation for the address.
+func (nt *NonceTracker) PendingTxHashes(addr cmn.Address) []string {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+
+	hashes := []string{}
+	for _, reservation := range nt.reservations[addr] {
+		if reservation.TxHash != "" {
+			hashes = append(hashes, reservation.TxHash)
+		}
+	}
+	return hashes
+}
+
+// ReserveNextSequence returns the next sequence to use for the address, like NextSequence, and
+// holds it for buildTTL so that a tx built before this one is submitted gets the following sequence.
+func (nt *NonceTracker) ReserveNextSequence(addr cmn.Address, committed uint64, pending uint64) uint64 {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+
+	next := nt.nextSequence(addr, committed, pending)
+	if _, ok := nt.reservations[addr]; !ok {
+		nt.reservations[addr] = make(map[uint64]*nonceReservation)
+	}
+	nt.reservations[addr][next] = &nonceReservation{
+		ExpiresAt: time.Now().Add(nt.buildTTL),
+	}
+	return next
+}
+
+// NextSequence returns the next sequence to use for the address, accounting for the committed
+// sequence, the sequence in the node's pending (screened) view and the adaptor's own
+// reservations. Reservations that landed on chain or expired are pruned.
+func (nt *NonceTracker) NextSequence(addr cmn.Address, committed uint64, pending uint64) uint64 {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+
+	return nt.nextSequence(addr, committed, pending)
+}
+
+func (nt *NonceTracker) nextSequence(addr cmn.Address, committed uint64, pending uint64) uint64 {
+	next := committed + 1
+	if pending+1 > next {
+		next = pending + 1
+	}
+
+	reservations, ok := nt.reservations[addr]
+	if !ok {
+		return next
+	}
+
+	now := time.Now()
+	for seq, reservation := range reservations {
+		if seq <= committed || now.After(reservation.ExpiresAt) {
+			delete(reservations, seq)
+		}
+	}
+	if len(reservations) == 0 {
+		delete(nt.reservations, addr)
+		return next
+	}
+
+	// skip over the contiguous reserved sequences
+	for {
+		if _, reserved := reservations[next]; !reserved {
+			break
+		}
+		next++
+	}
+	return next
+}
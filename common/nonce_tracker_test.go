@@ -0,0 +1,74 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	cmn "github.com/thetatoken/theta/common"
+)
+
+func TestNonceTrackerNextSequence(t *testing.T) {
+	addr := cmn.HexToAddress("0x2e833968e5bb786ae419c4d13189fb081cc43bab")
+
+	tests := []struct {
+		name      string
+		reserved  []uint64
+		committed uint64
+		pending   uint64
+		expected  uint64
+	}{
+		{"no reservation", nil, 4, 4, 5},
+		{"pending ahead of committed", nil, 4, 6, 7},
+		{"contiguous reservations", []uint64{5, 6}, 4, 4, 7},
+		{"gap in reservations", []uint64{5, 7}, 4, 4, 6},
+		{"committed reservations are pruned", []uint64{3, 4}, 4, 4, 5},
+	}
+	for _, test := range tests {
+		nt := NewNonceTracker(time.Minute, time.Minute)
+		for _, seq := range test.reserved {
+			nt.Reserve(addr, seq, "0xhash")
+		}
+		if next := nt.NextSequence(addr, test.committed, test.pending); next != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, next)
+		}
+	}
+}
+
+func TestNonceTrackerReserveNextSequence(t *testing.T) {
+	addr := cmn.HexToAddress("0x2e833968e5bb786ae419c4d13189fb081cc43bab")
+	nt := NewNonceTracker(time.Minute, time.Minute)
+
+	// two txs built back-to-back get distinct sequences
+	if next := nt.ReserveNextSequence(addr, 4, 4); next != 5 {
+		t.Fatalf("expected 5, got %v", next)
+	}
+	if next := nt.ReserveNextSequence(addr, 4, 4); next != 6 {
+		t.Fatalf("expected 6, got %v", next)
+	}
+
+	// built but not submitted txs are not reported as pending
+	if hashes := nt.PendingTxHashes(addr); len(hashes) != 0 {
+		t.Fatalf("expected no pending tx, got %v", hashes)
+	}
+
+	// submitting takes over the reservation, releasing it frees the sequence
+	nt.Reserve(addr, 5, "0xhash")
+	if hashes := nt.PendingTxHashes(addr); len(hashes) != 1 || hashes[0] != "0xhash" {
+		t.Fatalf("expected the submitted tx to be pending, got %v", hashes)
+	}
+	nt.Release("0xhash")
+	if next := nt.NextSequence(addr, 4, 4); next != 5 {
+		t.Fatalf("expected 5, got %v", next)
+	}
+}
+
+func TestNonceTrackerBuildReservationExpires(t *testing.T) {
+	addr := cmn.HexToAddress("0x2e833968e5bb786ae419c4d13189fb081cc43bab")
+	nt := NewNonceTracker(time.Minute, time.Millisecond)
+
+	nt.ReserveNextSequence(addr, 4, 4)
+	time.Sleep(5 * time.Millisecond)
+	if next := nt.NextSequence(addr, 4, 4); next != 5 {
+		t.Fatalf("expected the abandoned build reservation to expire, got %v", next)
+	}
+}
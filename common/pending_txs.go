@@ -0,0 +1,68 @@
+package common
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+type pendingTx struct {
+	RawTx     []byte
+	ExpiresAt time.Time
+}
+
+// PendingTxCache keeps the raw bytes of the txs submitted through the adaptor. The node only
+// reports the hashes of its pending txs, so the cached bytes are used to decode them.
+type PendingTxCache struct {
+	mu  sync.Mutex
+	ttl time.Duration
+	txs map[string]*pendingTx
+}
+
+func NewPendingTxCache(ttl time.Duration) *PendingTxCache {
+	return &PendingTxCache{
+		ttl: ttl,
+		txs: make(map[string]*pendingTx),
+	}
+}
+
+// Add records the raw bytes of a submitted tx.
+func (pc *PendingTxCache) Add(txHash string, rawTx []byte) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	pc.prune()
+	pc.txs[strings.ToLower(txHash)] = &pendingTx{
+		RawTx:     rawTx,
+		ExpiresAt: time.Now().Add(pc.ttl),
+	}
+}
+
+// Get returns the raw bytes of a submitted tx, if still cached.
+func (pc *PendingTxCache) Get(txHash string) ([]byte, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	tx, ok := pc.txs[strings.ToLower(txHash)]
+	if !ok || time.Now().After(tx.ExpiresAt) {
+		return nil, false
+	}
+	return tx.RawTx, true
+}
+
+// Remove drops a tx that left the mempool.
+func (pc *PendingTxCache) Remove(txHash string) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	delete(pc.txs, strings.ToLower(txHash))
+}
+
+func (pc *PendingTxCache) prune() {
+	now := time.Now()
+	for hash, tx := range pc.txs {
+		if now.After(tx.ExpiresAt) {
+			delete(pc.txs, hash)
+		}
+	}
+}
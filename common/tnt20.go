@@ -0,0 +1,130 @@
+package common
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/spf13/viper"
+
+	cmn "github.com/thetatoken/theta/common"
+)
+
+// Tnt20TransferSelector is the ABI method id of transfer(address,uint256)
+var Tnt20TransferSelector = []byte{0xa9, 0x05, 0x9c, 0xbb}
+
+const (
+	abiWordSize = 32
+
+	// TokenContractAddressKey is the Currency.Metadata key carrying a TNT-20 contract address
+	TokenContractAddressKey = "contract_address"
+)
+
+// Token describes a TNT-20 token served by the adaptor
+type Token struct {
+	Symbol          string `mapstructure:"symbol"`
+	Decimals        int32  `mapstructure:"decimals"`
+	ContractAddress string `mapstructure:"contractAddress"`
+}
+
+// GetTokens returns the TNT-20 tokens configured under rosetta.tokens
+func GetTokens() []Token {
+	var tokens []Token
+	if err := viper.UnmarshalKey(CfgRosettaTokens, &tokens); err != nil {
+		logger.Errorf("Failed to parse token config: %v", err)
+		return nil
+	}
+	return tokens
+}
+
+var subchainTokens = make(map[string][]Token)
+
+// SetSubchainTokens registers the TNT-20 tokens configured for a subchain
+func SetSubchainTokens(subchid string, tokens []Token) {
+	subchainTokens[strings.ToLower(subchid)] = tokens
+}
+
+// GetChainTokens returns the TNT-20 tokens served on a chain, those registered for a subchain
+// or rosetta.tokens for the main chains
+func GetChainTokens(chid string) []Token {
+	if tokens, ok := subchainTokens[strings.ToLower(chid)]; ok {
+		return tokens
+	}
+	return GetTokens()
+}
+
+// IsChainToken checks whether the token is served on the chain
+func IsChainToken(chid string, token *Token) bool {
+	for _, t := range GetChainTokens(chid) {
+		if strings.EqualFold(t.ContractAddress, token.ContractAddress) && t.Symbol == token.Symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTokenByContract looks up a token configured on any chain by its contract address
+func GetTokenByContract(contractAddr string) *Token {
+	tokens := GetTokens()
+	for _, chainTokens := range subchainTokens {
+		tokens = append(tokens, chainTokens...)
+	}
+	for _, token := range tokens {
+		if strings.EqualFold(token.ContractAddress, contractAddr) {
+			t := token
+			return &t
+		}
+	}
+	return nil
+}
+
+// GetTokenCurrency returns the Rosetta currency of a TNT-20 token
+func GetTokenCurrency(token *Token) *types.Currency {
+	return &types.Currency{
+		Symbol:   token.Symbol,
+		Decimals: token.Decimals,
+		Metadata: map[string]interface{}{TokenContractAddressKey: token.ContractAddress},
+	}
+}
+
+// GetTokenFromCurrency returns the configured token matching a currency that carries
+// a contract address in its metadata, or nil if the currency is not a known token
+func GetTokenFromCurrency(currency *types.Currency) *Token {
+	if currency == nil || currency.Metadata == nil {
+		return nil
+	}
+	contractAddr, ok := currency.Metadata[TokenContractAddressKey].(string)
+	if !ok {
+		return nil
+	}
+	token := GetTokenByContract(contractAddr)
+	if token == nil || !strings.EqualFold(token.Symbol, currency.Symbol) || token.Decimals != currency.Decimals {
+		return nil
+	}
+	return token
+}
+
+// EncodeTnt20Transfer encodes the calldata of transfer(to, amount)
+func EncodeTnt20Transfer(to cmn.Address, amount *big.Int) []byte {
+	data := make([]byte, 0, len(Tnt20TransferSelector)+2*abiWordSize)
+	data = append(data, Tnt20TransferSelector...)
+	data = append(data, cmn.LeftPadBytes(to.Bytes(), abiWordSize)...)
+	data = append(data, cmn.LeftPadBytes(amount.Bytes(), abiWordSize)...)
+	return data
+}
+
+// DecodeTnt20Transfer decodes the calldata of transfer(to, amount)
+func DecodeTnt20Transfer(data []byte) (to cmn.Address, amount *big.Int, ok bool) {
+	if len(data) != len(Tnt20TransferSelector)+2*abiWordSize || !bytes.Equal(data[:4], Tnt20TransferSelector) {
+		return to, nil, false
+	}
+	args := data[4:]
+	// the address word must be left padded with zeros
+	if !bytes.Equal(args[:abiWordSize-cmn.AddressLength], make([]byte, abiWordSize-cmn.AddressLength)) {
+		return to, nil, false
+	}
+	to = cmn.BytesToAddress(args[abiWordSize-cmn.AddressLength : abiWordSize])
+	amount = new(big.Int).SetBytes(args[abiWordSize : 2*abiWordSize])
+	return to, amount, true
+}
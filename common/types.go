@@ -35,6 +35,13 @@ func GetTFuelCurrency() *types.Currency {
 type TxType byte
 type TxStatus string
 
+const (
+	TxStatusNotFound  TxStatus = "not_found"
+	TxStatusPending   TxStatus = "pending"
+	TxStatusFinalized TxStatus = "finalized"
+	TxStatusAbandoned TxStatus = "abandoned"
+)
+
 type Tx struct {
 	ttypes.Tx      `json:"raw"`
 	Type           TxType                            `json:"type"`
@@ -64,6 +71,7 @@ func (t TxType) String() string {
 		"SlashTx",
 		"SendTx",
 		"ReserveFundTx",
+		"ReleaseFundTx",
 		"ServicePaymentTx",
 		"SplitRuleTx",
 		"SmartContractTx",
@@ -80,6 +88,7 @@ func TxTypes() []string {
 		"SlashTx",
 		"SendTx",
 		"ReserveFundTx",
+		"ReleaseFundTx",
 		"ServicePaymentTx",
 		"SplitRuleTx",
 		"SmartContractTx",
@@ -114,6 +123,8 @@ const (
 	StakeRewardDistributionTxHolder
 	StakeRewardDistributionTxBeneficiary
 	TxFee
+	CrossChainTransferOut
+	CrossChainTransferIn
 )
 
 func (t TxOpType) String() string {
@@ -137,6 +148,8 @@ func (t TxOpType) String() string {
 		"StakeRewardDistributionTxHolder",
 		"StakeRewardDistributionTxBeneficiary",
 		"TxFee",
+		"CrossChainTransferOut",
+		"CrossChainTransferIn",
 	}[t]
 }
 
@@ -161,6 +174,8 @@ func TxOpTypes() []string {
 		"StakeRewardDistributionTxHolder",
 		"StakeRewardDistributionTxBeneficiary",
 		"TxFee",
+		"CrossChainTransferOut",
+		"CrossChainTransferIn",
 	}
 }
 
@@ -2,6 +2,7 @@ package common
 
 import (
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"math/big"
@@ -17,7 +18,6 @@ import (
 	cmn "github.com/thetatoken/theta/common"
 	"github.com/thetatoken/theta/core"
 	"github.com/thetatoken/theta/crypto"
-	"github.com/thetatoken/theta/crypto/bls"
 	ttypes "github.com/thetatoken/theta/ledger/types"
 	jrpc "github.com/ybbus/jsonrpc"
 )
@@ -27,14 +27,54 @@ var logger *log.Entry = log.WithFields(log.Fields{"prefix": "utils"})
 const StakeWithdrawPrefix = "stake_withdraw"
 
 // ------------------------------ Chain ID -----------------------------------
-var chainId string
+var chainIds []string
 
+// GetChainId returns the chain ID of the default network, the first one served.
 func GetChainId() string {
-	return chainId
+	if len(chainIds) == 0 {
+		return ""
+	}
+	return chainIds[0]
+}
+
+// GetChainIds returns the chain IDs of all the served networks.
+func GetChainIds() []string {
+	return chainIds
 }
 
-func SetChainId(chid string) {
-	chainId = chid
+func SetChainIds(chids []string) {
+	chainIds = chids
+}
+
+var subchainIds = make(map[string][]string)
+
+// GetSubchainIds returns the chain IDs of the served subchains of a network.
+func GetSubchainIds(chid string) []string {
+	return subchainIds[strings.ToLower(chid)]
+}
+
+func SetSubchainIds(chid string, subchids []string) {
+	subchainIds[strings.ToLower(chid)] = subchids
+}
+
+// IsServedSubchainId checks whether the subchain ID is one of the served subchains of a network.
+func IsServedSubchainId(chid, subchid string) bool {
+	for _, subchainId := range GetSubchainIds(chid) {
+		if strings.EqualFold(subchid, subchainId) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsServedChainId checks whether the chain ID is one of the served networks.
+func IsServedChainId(chid string) bool {
+	for _, chainId := range chainIds {
+		if strings.EqualFold(chid, chainId) {
+			return true
+		}
+	}
+	return false
 }
 
 // ------------------------------ Theta RPC -----------------------------------
@@ -62,6 +102,43 @@ func HandleThetaRPCResponse(rpcRes *rpcc.RPCResponse, rpcErr error, parse func(j
 	return
 }
 
+// ------------------------------ Metadata Values -----------------------------------
+
+// ToBigInt converts a metadata value, which is a float64 or a decimal string once JSON
+// decoded, into a big.Int.
+func ToBigInt(v interface{}) (*big.Int, bool) {
+	switch val := v.(type) {
+	case *big.Int:
+		if val == nil {
+			return nil, false
+		}
+		return new(big.Int).Set(val), true
+	case float64:
+		res, _ := new(big.Float).SetFloat64(val).Int(nil)
+		return res, true
+	case uint64:
+		return new(big.Int).SetUint64(val), true
+	case int64:
+		return big.NewInt(val), true
+	case int:
+		return big.NewInt(int64(val)), true
+	case json.Number:
+		return new(big.Int).SetString(val.String(), 10)
+	case string:
+		return new(big.Int).SetString(val, 10)
+	}
+	return nil, false
+}
+
+// ToUint64 converts a metadata value into an uint64.
+func ToUint64(v interface{}) (uint64, bool) {
+	val, ok := ToBigInt(v)
+	if !ok || val.Sign() < 0 || !val.IsUint64() {
+		return 0, false
+	}
+	return val.Uint64(), true
+}
+
 // ------------------------------ Validate Network Identifier -----------------------------------
 
 func ValidateNetworkIdentifier(ctx context.Context, ni *types.NetworkIdentifier) *types.Error {
@@ -69,12 +146,12 @@ func ValidateNetworkIdentifier(ctx context.Context, ni *types.NetworkIdentifier)
 		if !strings.EqualFold(ni.Blockchain, ChainName) {
 			return ErrInvalidBlockchain
 		}
-		if ni.SubNetworkIdentifier != nil {
-			return ErrInvalidSubnetwork
-		}
-		if !strings.EqualFold(ni.Network, GetChainId()) {
+		if !IsServedChainId(ni.Network) {
 			return ErrInvalidNetwork
 		}
+		if ni.SubNetworkIdentifier != nil && !IsServedSubchainId(ni.Network, ni.SubNetworkIdentifier.Network) {
+			return ErrInvalidSubnetwork
+		}
 	} else {
 		return ErrMissingNID
 	}
@@ -257,9 +334,12 @@ func ParseSendTx(sendTx ttypes.SendTx, status *string, txType TxType) (metadata
 	var i int64
 	var inputAddr string
 
-	for _, input := range sendTx.Inputs {
+	// the fee is paid by the first input
+	for j, input := range sendTx.Inputs {
 		sigBytes, _ := input.Signature.MarshalJSON()
-		inputAddr = input.Address.String()
+		if j == 0 {
+			inputAddr = input.Address.String()
+		}
 
 		if input.Coins.ThetaWei == nil {
 			input.Coins.ThetaWei = big.NewInt(0)
@@ -284,7 +364,10 @@ func ParseSendTx(sendTx ttypes.SendTx, status *string, txType TxType) (metadata
 		if input.Coins.TFuelWei == nil {
 			input.Coins.TFuelWei = big.NewInt(0)
 		}
-		tfuelInput := new(big.Int).Sub(input.Coins.TFuelWei, sendTx.Fee.TFuelWei)
+		tfuelInput := input.Coins.TFuelWei
+		if j == 0 {
+			tfuelInput = new(big.Int).Sub(input.Coins.TFuelWei, sendTx.Fee.TFuelWei)
+		}
 		tfuelWei := new(big.Int).Mul(tfuelInput, big.NewInt(-1)).String()
 		tfuelInputOp := &types.Operation{
 			OperationIdentifier: &types.OperationIdentifier{Index: i},
@@ -374,7 +457,7 @@ func ParseReserveFundTx(reserveFundTx ttypes.ReserveFundTx, status *string, txTy
 	sigBytes, _ := reserveFundTx.Source.Signature.MarshalJSON()
 	var i int64
 
-	if reserveFundTx.Source.Coins.ThetaWei != nil && reserveFundTx.Source.Coins.ThetaWei != big.NewInt(0) {
+	if reserveFundTx.Source.Coins.ThetaWei != nil && reserveFundTx.Source.Coins.ThetaWei.Sign() != 0 {
 		op := &types.Operation{
 			OperationIdentifier: &types.OperationIdentifier{Index: i},
 			Type:                ReserveFundTxSource.String(),
@@ -389,7 +472,7 @@ func ParseReserveFundTx(reserveFundTx ttypes.ReserveFundTx, status *string, txTy
 		i++
 	}
 
-	if reserveFundTx.Source.Coins.TFuelWei != nil && reserveFundTx.Source.Coins.TFuelWei != big.NewInt(0) {
+	if reserveFundTx.Source.Coins.TFuelWei != nil && reserveFundTx.Source.Coins.TFuelWei.Sign() != 0 {
 		op := &types.Operation{
 			OperationIdentifier: &types.OperationIdentifier{Index: i},
 			Type:                ReserveFundTxSource.String(),
@@ -412,7 +495,6 @@ func ParseReserveFundTx(reserveFundTx ttypes.ReserveFundTx, status *string, txTy
 		Type:                TxFee.String(),
 		Account:             &types.AccountIdentifier{Address: reserveFundTx.Source.Address.String()},
 		Amount:              &types.Amount{Value: new(big.Int).Mul(reserveFundTx.Fee.TFuelWei, big.NewInt(-1)).String(), Currency: GetTFuelCurrency()},
-		RelatedOperations:   []*types.OperationIdentifier{{Index: 1}},
 	}
 	if status != nil {
 		fee.Status = status
@@ -434,7 +516,9 @@ func ParseReleaseFundTx(releaseFundTx ttypes.ReleaseFundTx, status *string, txTy
 	sigBytes, _ := releaseFundTx.Source.Signature.MarshalJSON()
 	var i int64
 
-	if releaseFundTx.Source.Coins.ThetaWei != nil && releaseFundTx.Source.Coins.ThetaWei != big.NewInt(0) {
+	// the released coins go back to the source, so both currencies are positive
+
+	if releaseFundTx.Source.Coins.ThetaWei != nil && releaseFundTx.Source.Coins.ThetaWei.Sign() != 0 {
 		op := &types.Operation{
 			OperationIdentifier: &types.OperationIdentifier{Index: i},
 			Type:                ReleaseFundTxSource.String(),
@@ -449,12 +533,12 @@ func ParseReleaseFundTx(releaseFundTx ttypes.ReleaseFundTx, status *string, txTy
 		i++
 	}
 
-	if releaseFundTx.Source.Coins.TFuelWei != nil && releaseFundTx.Source.Coins.TFuelWei != big.NewInt(0) {
+	if releaseFundTx.Source.Coins.TFuelWei != nil && releaseFundTx.Source.Coins.TFuelWei.Sign() != 0 {
 		op := &types.Operation{
 			OperationIdentifier: &types.OperationIdentifier{Index: i},
 			Type:                ReleaseFundTxSource.String(),
 			Account:             &types.AccountIdentifier{Address: releaseFundTx.Source.Address.String()},
-			Amount:              &types.Amount{Value: new(big.Int).Mul(releaseFundTx.Source.Coins.TFuelWei, big.NewInt(-1)).String(), Currency: GetTFuelCurrency()},
+			Amount:              &types.Amount{Value: releaseFundTx.Source.Coins.TFuelWei.String(), Currency: GetTFuelCurrency()},
 			Metadata:            map[string]interface{}{"sequence": releaseFundTx.Source.Sequence, "signature": sigBytes},
 		}
 		if status != nil {
@@ -472,7 +556,6 @@ func ParseReleaseFundTx(releaseFundTx ttypes.ReleaseFundTx, status *string, txTy
 		Type:                TxFee.String(),
 		Account:             &types.AccountIdentifier{Address: releaseFundTx.Source.Address.String()},
 		Amount:              &types.Amount{Value: new(big.Int).Mul(releaseFundTx.Fee.TFuelWei, big.NewInt(-1)).String(), Currency: GetTFuelCurrency()},
-		RelatedOperations:   []*types.OperationIdentifier{{Index: 1}},
 	}
 	if status != nil {
 		fee.Status = status
@@ -537,11 +620,13 @@ func ParseSplitRuleTx(splitRuleTx ttypes.SplitRuleTx, status *string, txType TxT
 	}
 
 	sigBytes, _ := splitRuleTx.Initiator.Signature.MarshalJSON()
+
+	// the initiator is an input of the tx, so its coins are negative like those of the other sources
 	op := &types.Operation{
 		OperationIdentifier: &types.OperationIdentifier{Index: 0},
 		Type:                SplitRuleTxInitiator.String(),
 		Account:             &types.AccountIdentifier{Address: splitRuleTx.Initiator.Address.String()},
-		Amount:              &types.Amount{Value: splitRuleTx.Initiator.Coins.TFuelWei.String(), Currency: GetTFuelCurrency()},
+		Amount:              &types.Amount{Value: new(big.Int).Neg(splitRuleTx.Initiator.Coins.NoNil().TFuelWei).String(), Currency: GetTFuelCurrency()},
 		Metadata:            map[string]interface{}{"sequence": splitRuleTx.Initiator.Sequence, "signature": sigBytes},
 	}
 
@@ -561,17 +646,63 @@ func ParseSplitRuleTx(splitRuleTx ttypes.SplitRuleTx, status *string, txType TxT
 	return
 }
 
+// ContractAddress returns the address of a contract deployed by the given tx sequence. The
+// ledger's EVM.Create derives it with crypto.CreateAddress from StateDB.GetNonce, i.e. the
+// sender's committed sequence, and the SmartContractTx executor only increments that sequence
+// after the VM ran, so the nonce used is one less than the tx sequence.
+func ContractAddress(from cmn.Address, sequence uint64) cmn.Address {
+	if sequence == 0 {
+		return crypto.CreateAddress(from, 0)
+	}
+	return crypto.CreateAddress(from, sequence-1)
+}
+
+// IsContractDeployment returns true if the smart contract tx has an empty To address.
+func IsContractDeployment(smartContractTx ttypes.SmartContractTx) bool {
+	return smartContractTx.To.Address == (cmn.Address{})
+}
+
 func ParseSmartContractTxForConstruction(smartContractTx ttypes.SmartContractTx, txType TxType) (metadata map[string]interface{}, ops []*types.Operation) {
 	metadata = map[string]interface{}{
 		"type":      txType,
 		"gas_limit": smartContractTx.GasLimit,
 		"gas_price": smartContractTx.GasPrice,
-		"data":      smartContractTx.Data,
+		"data":      hex.EncodeToString(smartContractTx.Data), // hex encoded, as passed to /construction/payloads
+	}
+
+	deploy := IsContractDeployment(smartContractTx)
+	if deploy {
+		metadata["contract_address"] = ContractAddress(smartContractTx.From.Address, smartContractTx.From.Sequence).Hex()
 	}
 
 	sigBytes, _ := smartContractTx.From.Signature.MarshalJSON()
 	var i int64
 
+	// a token transfer carrying TFuel value is parsed as a plain contract call, so that the
+	// value is not dropped from the operations
+	carriesValue := !smartContractTx.From.Coins.NoNil().IsZero() || !smartContractTx.To.Coins.NoNil().IsZero()
+	if token := GetTokenByContract(smartContractTx.To.Address.Hex()); token != nil && !deploy && !carriesValue {
+		if to, amount, ok := DecodeTnt20Transfer(smartContractTx.Data); ok {
+			metadata["token_contract"] = token.ContractAddress
+			tokenFrom := &types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{Index: 0},
+				Type:                SmartContractTxFrom.String(),
+				Account:             &types.AccountIdentifier{Address: smartContractTx.From.Address.String()},
+				Amount:              &types.Amount{Value: new(big.Int).Mul(amount, big.NewInt(-1)).String(), Currency: GetTokenCurrency(token)},
+				Metadata:            map[string]interface{}{"sequence": smartContractTx.From.Sequence, "signature": sigBytes},
+			}
+			tokenTo := &types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{Index: 1},
+				RelatedOperations:   []*types.OperationIdentifier{{Index: 0}},
+				Type:                SmartContractTxTo.String(),
+				Account:             &types.AccountIdentifier{Address: to.String()},
+				Amount:              &types.Amount{Value: amount.String(), Currency: GetTokenCurrency(token)},
+			}
+			ops = []*types.Operation{tokenFrom, tokenTo}
+			return
+		}
+	}
+
 	if smartContractTx.From.Coins.ThetaWei != nil && len(smartContractTx.From.Coins.ThetaWei.Bits()) != 0 {
 		thetaFrom := types.Operation{
 			OperationIdentifier: &types.OperationIdentifier{Index: i},
@@ -586,7 +717,11 @@ func ParseSmartContractTxForConstruction(smartContractTx ttypes.SmartContractTx,
 		ops = append(ops, &thetaFrom)
 		i++
 	}
-	if smartContractTx.From.Coins.TFuelWei != nil && len(smartContractTx.From.Coins.TFuelWei.Bits()) != 0 {
+	if deploy && smartContractTx.From.Coins.TFuelWei == nil {
+		smartContractTx.From.Coins.TFuelWei = big.NewInt(0)
+	}
+	// a deployment always carries its From operation, even with zero value
+	if smartContractTx.From.Coins.TFuelWei != nil && (deploy || len(smartContractTx.From.Coins.TFuelWei.Bits()) != 0) {
 		tfuelFrom := types.Operation{
 			OperationIdentifier: &types.OperationIdentifier{Index: i},
 			Type:                SmartContractTxFrom.String(),
@@ -717,7 +852,7 @@ func ParseDepositStakeTx(depositStakeTx ttypes.DepositStakeTxV2, status *string,
 	sigBytes, _ := depositStakeTx.Source.Signature.MarshalJSON()
 	var i int64
 
-	if depositStakeTx.Source.Coins.ThetaWei != nil && depositStakeTx.Source.Coins.ThetaWei != big.NewInt(0) {
+	if depositStakeTx.Source.Coins.ThetaWei != nil && depositStakeTx.Source.Coins.ThetaWei.Sign() != 0 {
 		thetaSource := &types.Operation{
 			OperationIdentifier: &types.OperationIdentifier{Index: i},
 			Type:                DepositStakeTxSource.String(),
@@ -735,7 +870,7 @@ func ParseDepositStakeTx(depositStakeTx ttypes.DepositStakeTxV2, status *string,
 		i++
 	}
 
-	if depositStakeTx.Source.Coins.TFuelWei != nil && depositStakeTx.Source.Coins.TFuelWei != big.NewInt(0) {
+	if depositStakeTx.Source.Coins.TFuelWei != nil && depositStakeTx.Source.Coins.TFuelWei.Sign() != 0 {
 		tfuelSource := &types.Operation{
 			OperationIdentifier: &types.OperationIdentifier{Index: i},
 			Type:                DepositStakeTxSource.String(),
@@ -798,7 +933,7 @@ func ParseReturnStakeTx(withdrawStakeTx ttypes.WithdrawStakeTx, status *string,
 	sigBytes, _ := withdrawStakeTx.Source.Signature.MarshalJSON()
 	var i int64
 
-	if withdrawStakeTx.Source.Coins.ThetaWei != nil && withdrawStakeTx.Source.Coins.ThetaWei != big.NewInt(0) {
+	if withdrawStakeTx.Source.Coins.ThetaWei != nil && withdrawStakeTx.Source.Coins.ThetaWei.Sign() != 0 {
 		thetaSource := &types.Operation{
 			OperationIdentifier: &types.OperationIdentifier{Index: i},
 			Type:                WithdrawStakeTxSource.String(),
@@ -816,7 +951,7 @@ func ParseReturnStakeTx(withdrawStakeTx ttypes.WithdrawStakeTx, status *string,
 		i++
 	}
 
-	if withdrawStakeTx.Source.Coins.TFuelWei != nil && withdrawStakeTx.Source.Coins.TFuelWei != big.NewInt(0) {
+	if withdrawStakeTx.Source.Coins.TFuelWei != nil && withdrawStakeTx.Source.Coins.TFuelWei.Sign() != 0 {
 		tfuelSource := &types.Operation{
 			OperationIdentifier: &types.OperationIdentifier{Index: i},
 			Type:                WithdrawStakeTxSource.String(),
@@ -844,10 +979,9 @@ func ParseStakeRewardDistributionTx(stakeRewardDistributionTx ttypes.StakeReward
 
 	var i int64
 
-	if stakeRewardDistributionTx.Beneficiary.Coins.ThetaWei != nil && stakeRewardDistributionTx.Beneficiary.Coins.ThetaWei != big.NewInt(0) {
+	if stakeRewardDistributionTx.Beneficiary.Coins.ThetaWei != nil && stakeRewardDistributionTx.Beneficiary.Coins.ThetaWei.Sign() != 0 {
 		thetaOutput := &types.Operation{
 			OperationIdentifier: &types.OperationIdentifier{Index: i},
-			RelatedOperations:   []*types.OperationIdentifier{{Index: i - 1}},
 			Type:                StakeRewardDistributionTxBeneficiary.String(),
 			Account:             &types.AccountIdentifier{Address: stakeRewardDistributionTx.Beneficiary.Address.String()},
 			Amount:              &types.Amount{Value: stakeRewardDistributionTx.Beneficiary.Coins.ThetaWei.String(), Currency: GetThetaCurrency()},
@@ -862,10 +996,9 @@ func ParseStakeRewardDistributionTx(stakeRewardDistributionTx ttypes.StakeReward
 		i++
 	}
 
-	if stakeRewardDistributionTx.Beneficiary.Coins.TFuelWei != nil && stakeRewardDistributionTx.Beneficiary.Coins.TFuelWei != big.NewInt(0) {
+	if stakeRewardDistributionTx.Beneficiary.Coins.TFuelWei != nil && stakeRewardDistributionTx.Beneficiary.Coins.TFuelWei.Sign() != 0 {
 		tfuelOutput := &types.Operation{
 			OperationIdentifier: &types.OperationIdentifier{Index: i},
-			RelatedOperations:   []*types.OperationIdentifier{{Index: i - 1}},
 			Type:                StakeRewardDistributionTxBeneficiary.String(),
 			Account:             &types.AccountIdentifier{Address: stakeRewardDistributionTx.Beneficiary.Address.String()},
 			Amount:              &types.Amount{Value: stakeRewardDistributionTx.Beneficiary.Coins.TFuelWei.String(), Currency: GetTFuelCurrency()},
@@ -897,11 +1030,72 @@ func ParseStakeRewardDistributionTx(stakeRewardDistributionTx ttypes.StakeReward
 	return
 }
 
+// TxHash returns the hash of a raw encoded tx, the same way the node computes it. Only the
+// Theta tx encoding is hashed here, callers check the raw tx decodes with ttypes.TxFromBytes.
+func TxHash(rawTx []byte) cmn.Hash {
+	return crypto.Keccak256Hash(rawTx)
+}
+
+// decodeTx decodes the json representation of a tx returned by the node.
+func decodeTx(txType TxType, rawTx json.RawMessage) ttypes.Tx {
+	var tx ttypes.Tx
+	switch txType {
+	case CoinbaseTx:
+		tx = &ttypes.CoinbaseTx{}
+	case SlashTx:
+		tx = &ttypes.SlashTx{}
+	case SendTx:
+		tx = &ttypes.SendTx{}
+	case ReserveFundTx:
+		tx = &ttypes.ReserveFundTx{}
+	case ReleaseFundTx:
+		tx = &ttypes.ReleaseFundTx{}
+	case ServicePaymentTx:
+		tx = &ttypes.ServicePaymentTx{}
+	case SplitRuleTx:
+		tx = &ttypes.SplitRuleTx{}
+	case SmartContractTx:
+		tx = &ttypes.SmartContractTx{}
+	case DepositStakeTx:
+		tx = &ttypes.DepositStakeTx{}
+	case DepositStakeV2Tx:
+		tx = &ttypes.DepositStakeTxV2{}
+	case WithdrawStakeTx:
+		tx = &ttypes.WithdrawStakeTx{}
+	case StakeRewardDistributionTx:
+		tx = &ttypes.StakeRewardDistributionTx{}
+	default:
+		return nil
+	}
+	if err := json.Unmarshal(rawTx, tx); err != nil {
+		return nil
+	}
+	return tx
+}
+
+// CheckTxHash logs when the hash reported by the node differs from the one TxHash computes
+// for the re-encoded tx, which is the hash /construction/hash and /construction/submit report.
+func CheckTxHash(tx ttypes.Tx, txHash cmn.Hash) bool {
+	raw, err := ttypes.TxToBytes(tx)
+	if err != nil {
+		return false
+	}
+	if hash := TxHash(raw); hash != txHash {
+		logger.Errorf("Tx hash mismatch, node: %v, computed: %v", txHash.Hex(), hash.Hex())
+		return false
+	}
+	return true
+}
+
 func ParseTx(txType TxType, rawTx json.RawMessage, txHash cmn.Hash, status *string, gasUsed uint64, balanceChanges *blockchain.TxBalanceChangesEntry, db *LDBDatabase, stakeService *StakeService, blockHeight cmn.JSONUint64) types.Transaction {
 	transaction := types.Transaction{
 		TransactionIdentifier: &types.TransactionIdentifier{Hash: txHash.String()},
 	}
 
+	if tx := decodeTx(txType, rawTx); tx != nil {
+		CheckTxHash(tx, txHash)
+	}
+
 	switch txType {
 	case CoinbaseTx:
 		coinbaseTx := ttypes.CoinbaseTx{}
@@ -996,283 +1190,64 @@ func ParseTx(txType TxType, rawTx json.RawMessage, txHash cmn.Hash, status *stri
 	return transaction
 }
 
-func AssembleTx(ops []*types.Operation, meta map[string]interface{}) (tx ttypes.Tx, err error) {
-	var ok bool
-	var typ, seq interface{}
-	if typ, ok = meta["type"]; !ok {
-		return nil, fmt.Errorf("missing tx type")
-	}
-	if seq, ok = meta["sequence"]; !ok {
-		return nil, fmt.Errorf("missing tx sequence")
+// GetTxInputs returns the inputs that need to sign the tx.
+func GetTxInputs(tx ttypes.Tx) []ttypes.TxInput {
+	switch tran := tx.(type) {
+	case *ttypes.CoinbaseTx:
+		return []ttypes.TxInput{tran.Proposer}
+	case *ttypes.SlashTx:
+		return []ttypes.TxInput{tran.Proposer}
+	case *ttypes.SendTx:
+		return tran.Inputs
+	case *ttypes.ReserveFundTx:
+		return []ttypes.TxInput{tran.Source}
+	case *ttypes.ReleaseFundTx:
+		return []ttypes.TxInput{tran.Source}
+	case *ttypes.ServicePaymentTx:
+		return []ttypes.TxInput{tran.Source, tran.Target}
+	case *ttypes.SplitRuleTx:
+		return []ttypes.TxInput{tran.Initiator}
+	case *ttypes.SmartContractTx:
+		return []ttypes.TxInput{tran.From}
+	case *ttypes.DepositStakeTx:
+		return []ttypes.TxInput{tran.Source}
+	case *ttypes.DepositStakeTxV2:
+		return []ttypes.TxInput{tran.Source}
+	case *ttypes.WithdrawStakeTx:
+		return []ttypes.TxInput{tran.Source}
+	case *ttypes.StakeRewardDistributionTx:
+		return []ttypes.TxInput{tran.Holder}
 	}
+	return nil
+}
 
-	txType := TxType(typ.(float64))
-	sequence := uint64(seq.(float64))
-	fee := ttypes.Coins{TFuelWei: big.NewInt(int64(meta["fee"].(float64)))}
-
-	switch txType {
-	case CoinbaseTx:
-		inputAmount := new(big.Int)
-		inputAmount.SetString(ops[0].Amount.Value, 10)
-
-		outputs := []ttypes.TxOutput{}
-		for i := 1; i < len(ops); i++ {
-			tFuelWei := new(big.Int)
-			tFuelWei.SetString(ops[i].Amount.Value, 10)
-
-			output := ttypes.TxOutput{
-				Address: cmn.HexToAddress(ops[i].Account.Address),
-				Coins:   ttypes.Coins{TFuelWei: tFuelWei},
-			}
-			outputs = append(outputs, output)
-		}
-
-		tx = &ttypes.CoinbaseTx{
-			Proposer: ttypes.TxInput{
-				Address:  cmn.HexToAddress(ops[0].Account.Address),
-				Coins:    ttypes.Coins{TFuelWei: inputAmount},
-				Sequence: sequence,
-			},
-			Outputs: outputs,
-		}
-
-	case SlashTx:
-		inputAmount := new(big.Int)
-		inputAmount.SetString(ops[0].Amount.Value, 10)
-
-		tx = &ttypes.SlashTx{
-			Proposer: ttypes.TxInput{
-				Address:  cmn.HexToAddress(ops[0].Account.Address),
-				Coins:    ttypes.Coins{TFuelWei: inputAmount},
-				Sequence: sequence,
-			},
-		}
-	case SendTx:
-		var inputs []ttypes.TxInput
-		var outputs []ttypes.TxOutput
-
-		inputMap := make(map[string][]*types.Operation)
-		outputMap := make(map[string][]*types.Operation)
-		for _, op := range ops {
-			if op.Type == SendTxInput.String() {
-				inputMap[op.Account.Address] = append(inputMap[op.Account.Address], op)
-			} else if op.Type == SendTxOutput.String() {
-				outputMap[op.Account.Address] = append(outputMap[op.Account.Address], op)
-			}
-		}
-
-		for addr, ops := range inputMap {
-			input := ttypes.TxInput{
-				Address:  cmn.HexToAddress(addr),
-				Sequence: sequence,
-			}
-			for _, op := range ops {
-				coin := new(big.Int)
-				coin.SetString(op.Amount.Value, 10)
-				if strings.EqualFold(op.Amount.Currency.Symbol, GetThetaCurrency().Symbol) {
-					input.Coins.ThetaWei = coin
-				} else if strings.EqualFold(op.Amount.Currency.Symbol, GetTFuelCurrency().Symbol) {
-					input.Coins.TFuelWei = coin
-				}
-			}
-			inputs = append(inputs, input)
-		}
-		for addr, ops := range outputMap {
-			output := ttypes.TxOutput{
-				Address: cmn.HexToAddress(addr),
-			}
-			for _, op := range ops {
-				coin := new(big.Int)
-				coin.SetString(op.Amount.Value, 10)
-				if strings.EqualFold(op.Amount.Currency.Symbol, GetThetaCurrency().Symbol) {
-					output.Coins.ThetaWei = coin
-				} else if strings.EqualFold(op.Amount.Currency.Symbol, GetTFuelCurrency().Symbol) {
-					output.Coins.TFuelWei = coin
-				}
-			}
-			outputs = append(outputs, output)
-		}
-
-		tx = &ttypes.SendTx{
-			Fee:     fee,
-			Inputs:  inputs,
-			Outputs: outputs,
-		}
-
-	case ReserveFundTx:
-		inputAmount := new(big.Int)
-		inputAmount.SetString(ops[0].Amount.Value, 10)
-
-		tx = &ttypes.ReserveFundTx{
-			Fee: fee,
-			Source: ttypes.TxInput{
-				Address:  cmn.HexToAddress(ops[0].Account.Address),
-				Coins:    ttypes.Coins{TFuelWei: inputAmount},
-				Sequence: sequence,
-			},
-			Collateral:  meta["collateral"].(ttypes.Coins),
-			ResourceIDs: meta["resource_ids"].([]string),
-			Duration:    uint64(meta["duration"].(float64)),
-		}
-
-	case ReleaseFundTx:
-		inputAmount := new(big.Int)
-		inputAmount.SetString(ops[0].Amount.Value, 10)
-
-		tx = &ttypes.ReleaseFundTx{
-			Fee: fee,
-			Source: ttypes.TxInput{
-				Address:  cmn.HexToAddress(ops[0].Account.Address),
-				Coins:    ttypes.Coins{TFuelWei: inputAmount},
-				Sequence: sequence,
-			},
-			ReserveSequence: uint64(meta["reserve_sequence"].(float64)),
-		}
-
-	case ServicePaymentTx:
-		sourceAmount := new(big.Int)
-		sourceAmount.SetString(ops[0].Amount.Value, 10)
-		targetAmount := new(big.Int)
-		targetAmount.SetString(ops[1].Amount.Value, 10)
-
-		tx = &ttypes.ServicePaymentTx{
-			Fee: fee,
-			Source: ttypes.TxInput{
-				Address:  cmn.HexToAddress(ops[0].Account.Address),
-				Coins:    ttypes.Coins{TFuelWei: sourceAmount},
-				Sequence: sequence,
-			},
-			Target: ttypes.TxInput{
-				Address: cmn.HexToAddress(ops[1].Account.Address),
-				Coins:   ttypes.Coins{TFuelWei: targetAmount},
-			},
-			PaymentSequence: uint64(meta["payment_sequence"].(float64)),
-			ReserveSequence: uint64(meta["reserve_sequence"].(float64)),
-			ResourceID:      meta["resource_id"].(string),
-		}
-
-	case SplitRuleTx:
-		sourceAmount := new(big.Int)
-		sourceAmount.SetString(ops[0].Amount.Value, 10)
-
-		tx = &ttypes.SplitRuleTx{
-			Fee: fee,
-			Initiator: ttypes.TxInput{
-				Address:  cmn.HexToAddress(ops[0].Account.Address),
-				Coins:    ttypes.Coins{TFuelWei: sourceAmount},
-				Sequence: sequence,
-			},
-			ResourceID: meta["resource_id"].(string),
-			Splits:     meta["splits"].([]ttypes.Split),
-			Duration:   uint64(meta["duration"].(float64)),
-		}
-
-	case SmartContractTx:
-		fromTFuelWei := new(big.Int)
-		fromTFuelWei.SetString(ops[0].Amount.Value, 10)
-		toTFuelWei := new(big.Int)
-		toTFuelWei.SetString(ops[1].Amount.Value, 10)
-
-		tx = &ttypes.SmartContractTx{
-			From: ttypes.TxInput{
-				Address:  cmn.HexToAddress(ops[0].Account.Address),
-				Coins:    ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: fromTFuelWei},
-				Sequence: sequence,
-			},
-			To: ttypes.TxOutput{
-				Address: cmn.HexToAddress(ops[1].Account.Address),
-				Coins:   ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: toTFuelWei},
-			},
-			GasLimit: uint64(meta["gas_limit"].(float64)),
-			GasPrice: big.NewInt(int64(meta["gas_price"].(float64))), //new(big.Int).Set(meta["gas_price"].(*big.Int)),
-			Data:     meta["data"].([]byte),
-		}
-
-	case DepositStakeTx, DepositStakeV2Tx:
-		sourceThetaWei := new(big.Int)
-		sourceThetaWei.SetString(ops[0].Amount.Value, 10)
-		sourceTFuelWei := new(big.Int)
-		sourceTFuelWei.SetString(ops[1].Amount.Value, 10)
-		holderThetaWei := new(big.Int)
-		holderThetaWei.SetString(ops[2].Amount.Value, 10)
-		holderTFuelWei := new(big.Int)
-		holderTFuelWei.SetString(ops[3].Amount.Value, 10)
-
-		depositStakeTx := &ttypes.DepositStakeTxV2{
-			Fee:     fee,
-			Purpose: uint8(meta["purpose"].(float64)),
-			Source: ttypes.TxInput{
-				Address:  cmn.HexToAddress(ops[0].Account.Address),
-				Coins:    ttypes.Coins{ThetaWei: sourceThetaWei, TFuelWei: sourceTFuelWei},
-				Sequence: sequence,
-			},
-			Holder: ttypes.TxOutput{
-				Address: cmn.HexToAddress(ops[1].Account.Address),
-				Coins:   ttypes.Coins{ThetaWei: holderThetaWei, TFuelWei: holderTFuelWei},
-			},
-		}
-
-		if blsPubkey, ok := meta["bls_pub_key"]; ok {
-			depositStakeTx.BlsPubkey = blsPubkey.(*bls.PublicKey)
-		}
-		if blsPop, ok := meta["bls_pop"]; ok {
-			depositStakeTx.BlsPop = blsPop.(*bls.Signature)
-		}
-		if holderSig, ok := meta["holder_sig"]; ok {
-			depositStakeTx.HolderSig = holderSig.(*crypto.Signature)
-		}
-		tx = depositStakeTx
-
-	case WithdrawStakeTx:
-		sourceThetaWei := new(big.Int)
-		sourceThetaWei.SetString(ops[0].Amount.Value, 10)
-		sourceTFuelWei := new(big.Int)
-		sourceTFuelWei.SetString(ops[1].Amount.Value, 10)
-		holderThetaWei := new(big.Int)
-		holderThetaWei.SetString(ops[2].Amount.Value, 10)
-		holderTFuelWei := new(big.Int)
-		holderTFuelWei.SetString(ops[3].Amount.Value, 10)
-
-		tx = &ttypes.WithdrawStakeTx{
-			Fee:     fee,
-			Purpose: uint8(meta["purpose"].(float64)),
-			Source: ttypes.TxInput{
-				Address:  cmn.HexToAddress(ops[0].Account.Address),
-				Coins:    ttypes.Coins{ThetaWei: sourceThetaWei, TFuelWei: sourceTFuelWei},
-				Sequence: sequence,
-			},
-			Holder: ttypes.TxOutput{
-				Address: cmn.HexToAddress(ops[1].Account.Address),
-				Coins:   ttypes.Coins{ThetaWei: holderThetaWei, TFuelWei: holderTFuelWei},
-			},
-		}
+// GetTxSequencedInputs returns the inputs whose sequence is checked by the node. The source
+// of a service payment is paid from its reserved fund and keeps its sequence.
+func GetTxSequencedInputs(tx ttypes.Tx) []ttypes.TxInput {
+	if tran, ok := tx.(*ttypes.ServicePaymentTx); ok {
+		return []ttypes.TxInput{tran.Target}
+	}
+	return GetTxInputs(tx)
+}
 
-	case StakeRewardDistributionTx:
-		holderThetaWei := new(big.Int)
-		holderThetaWei.SetString(ops[0].Amount.Value, 10)
-		holderTFuelWei := new(big.Int)
-		holderTFuelWei.SetString(ops[1].Amount.Value, 10)
-		beneficiaryThetaWei := new(big.Int)
-		beneficiaryThetaWei.SetString(ops[2].Amount.Value, 10)
-		beneficiaryTFuelWei := new(big.Int)
-		beneficiaryTFuelWei.SetString(ops[3].Amount.Value, 10)
-
-		tx = &ttypes.StakeRewardDistributionTx{
-			Fee:             fee,
-			SplitBasisPoint: uint(meta["split_basis_point"].(float64)),
-			Holder: ttypes.TxInput{
-				Address:  cmn.HexToAddress(ops[0].Account.Address),
-				Coins:    ttypes.Coins{ThetaWei: holderThetaWei, TFuelWei: holderTFuelWei},
-				Sequence: sequence,
-			},
-			Beneficiary: ttypes.TxOutput{
-				Address: cmn.HexToAddress(ops[1].Account.Address),
-				Coins:   ttypes.Coins{ThetaWei: beneficiaryThetaWei, TFuelWei: beneficiaryTFuelWei},
-			},
+// GetTxSignBytes returns the bytes the signer signs. The source and the target of a service
+// payment sign different bytes.
+func GetTxSignBytes(tx ttypes.Tx, signer cmn.Address, chainID string) []byte {
+	if tran, ok := tx.(*ttypes.ServicePaymentTx); ok {
+		if signer == tran.Source.Address {
+			return tran.SourceSignBytes(chainID)
 		}
+		return tran.TargetSignBytes(chainID)
+	}
+	return tx.SignBytes(chainID)
+}
 
-	default:
-		return nil, fmt.Errorf("unsupported tx type")
+// GetTxSigners returns the accounts that need to sign the tx.
+func GetTxSigners(tx ttypes.Tx) []cmn.Address {
+	inputs := GetTxInputs(tx)
+	signers := make([]cmn.Address, 0, len(inputs))
+	for _, input := range inputs {
+		signers = append(signers, input.Address)
 	}
-	return
+	return signers
 }
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	cmn "github.com/thetatoken/theta/common"
+	ttypes "github.com/thetatoken/theta/ledger/types"
+)
+
+func TestContractAddress(t *testing.T) {
+	from := cmn.HexToAddress("0x6ac7ea33f8831ea9dcc53393aaa88b25a785dbf0")
+
+	// the addresses created by from at nonces 0, 1 and 2, a tx sequence is its nonce plus one
+	tests := []struct {
+		sequence uint64
+		expected string
+	}{
+		{1, "0xcd234a471b72ba2f1ccf0a70fcaba648a5eecd8d"},
+		{2, "0x343c43a37d37dff08ae8c4a11544c718abb4fcf8"},
+		{3, "0xf778b86fa74e846c4f0a1fbd1335fe81c00a0c91"},
+	}
+	for _, test := range tests {
+		if addr := ContractAddress(from, test.sequence); addr != cmn.HexToAddress(test.expected) {
+			t.Errorf("sequence %v: expected %v, got %v", test.sequence, test.expected, addr.Hex())
+		}
+	}
+}
+
+func TestGetTxSignBytes(t *testing.T) {
+	chainID := "privatenet"
+	source := cmn.HexToAddress("0x2e833968e5bb786ae419c4d13189fb081cc43bab")
+	target := cmn.HexToAddress("0x9f1233798e905e173560071255140b4a8abd3ec6")
+	coins := func(tfuel int64) ttypes.Coins {
+		return ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(tfuel)}
+	}
+
+	servicePaymentTx := &ttypes.ServicePaymentTx{
+		Fee:             coins(1000),
+		Source:          ttypes.TxInput{Address: source, Coins: coins(500), Sequence: 1},
+		Target:          ttypes.TxInput{Address: target, Coins: coins(500), Sequence: 1},
+		PaymentSequence: 1,
+		ReserveSequence: 1,
+		ResourceID:      "rid001",
+	}
+	sendTx := &ttypes.SendTx{
+		Fee:     coins(1000),
+		Inputs:  []ttypes.TxInput{{Address: source, Coins: coins(1500), Sequence: 1}},
+		Outputs: []ttypes.TxOutput{{Address: target, Coins: coins(500)}},
+	}
+
+	tests := []struct {
+		name     string
+		tx       ttypes.Tx
+		signer   cmn.Address
+		expected []byte
+	}{
+		{"service payment source", servicePaymentTx, source, servicePaymentTx.SourceSignBytes(chainID)},
+		{"service payment target", servicePaymentTx, target, servicePaymentTx.TargetSignBytes(chainID)},
+		{"send", sendTx, source, sendTx.SignBytes(chainID)},
+	}
+	for _, test := range tests {
+		if signBytes := GetTxSignBytes(test.tx, test.signer, chainID); !bytes.Equal(signBytes, test.expected) {
+			t.Errorf("%v: unexpected sign bytes", test.name)
+		}
+	}
+
+	// the source and target of a service payment sign different bytes
+	if bytes.Equal(GetTxSignBytes(servicePaymentTx, source, chainID), GetTxSignBytes(servicePaymentTx, target, chainID)) {
+		t.Errorf("service payment source and target sign the same bytes")
+	}
+}
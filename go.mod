@@ -3,6 +3,7 @@ module github.com/thetatoken/theta-rosetta-rpc-adaptor
 require (
 	github.com/coinbase/rosetta-sdk-go v0.6.10
 	github.com/dgraph-io/badger v1.6.1 // indirect
+	github.com/gorilla/websocket v1.4.2
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/sirupsen/logrus v1.6.0
 	github.com/spf13/cobra v1.1.1
@@ -11,6 +12,7 @@ require (
 	github.com/thetatoken/theta v0.0.0
 	github.com/thetatoken/theta/common v0.0.0
 	github.com/ybbus/jsonrpc v2.1.2+incompatible
+	golang.org/x/term v0.0.0-20210927222741-03fcf44c2211
 )
 
 replace github.com/thetatoken/theta v0.0.0 => ../theta
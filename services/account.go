@@ -53,6 +53,14 @@ func (s *accountAPIService) AccountBalance(
 		return nil, err
 	}
 
+	// the mempool view only exists on top of the latest state
+	includeMempool, _ := getRequestMetadata(ctx)["include_mempool"].(bool)
+	if includeMempool && request.BlockIdentifier != nil {
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+		terr.Message += "include_mempool cannot be combined with a block identifier"
+		return nil, terr
+	}
+
 	var blockHeight common.JSONUint64
 	var blockHash string
 	if request.BlockIdentifier == nil {
@@ -83,9 +91,26 @@ func (s *accountAPIService) AccountBalance(
 		}
 	}
 
+	var sequence uint64
+	if includeMempool {
+		seq, err := s.getSequence(request.AccountIdentifier.Address)
+		if err != nil {
+			return nil, cmn.ErrUnableToGetAccount
+		}
+		sequence = seq
+	}
+
+	// the node only projects the pending txs on top of its latest state, queried at height 0,
+	// which is reported as the latest finalized block like /account/coins does
+	queryHeight := blockHeight
+	if includeMempool {
+		queryHeight = 0
+	}
+
 	rpcRes, rpcErr := s.client.Call("theta.GetAccount", GetAccountArgs{
 		Address: request.AccountIdentifier.Address,
-		Height:  blockHeight,
+		Height:  queryHeight,
+		Preview: includeMempool,
 	})
 
 	if rpcRes != nil && rpcRes.Error != nil && rpcRes.Error.Code == -32000 {
@@ -113,7 +138,11 @@ func (s *accountAPIService) AccountBalance(
 
 		resp := types.AccountBalanceResponse{}
 		resp.BlockIdentifier = &types.BlockIdentifier{Index: int64(blockHeight), Hash: blockHash}
-		resp.Metadata = map[string]interface{}{"sequence_number": account.Sequence}
+		if includeMempool {
+			resp.Metadata = map[string]interface{}{"sequence_number": sequence, "pending_sequence_number": account.Sequence}
+		} else {
+			resp.Metadata = map[string]interface{}{"sequence_number": account.Sequence}
+		}
 
 		var needTheta, needTFuel bool
 		if request.Currencies != nil {
@@ -171,8 +200,18 @@ func (s *accountAPIService) AccountCoins(
 	status, err := cmn.GetStatus(s.client)
 	blockIdentifier := &types.BlockIdentifier{Index: int64(status.LatestFinalizedBlockHeight), Hash: status.LatestFinalizedBlockHash.String()}
 
+	var sequence uint64
+	if request.IncludeMempool {
+		seq, err := s.getSequence(request.AccountIdentifier.Address)
+		if err != nil {
+			return nil, cmn.ErrUnableToGetAccount
+		}
+		sequence = seq
+	}
+
 	rpcRes, rpcErr := s.client.Call("theta.GetAccount", GetAccountArgs{
 		Address: request.AccountIdentifier.Address,
+		Preview: request.IncludeMempool,
 	})
 
 	if rpcRes != nil && rpcRes.Error != nil && rpcRes.Error.Code == -32000 {
@@ -204,7 +243,11 @@ func (s *accountAPIService) AccountCoins(
 
 		resp := types.AccountCoinsResponse{}
 		resp.BlockIdentifier = blockIdentifier
-		resp.Metadata = map[string]interface{}{"sequence_number": account.Sequence}
+		if request.IncludeMempool {
+			resp.Metadata = map[string]interface{}{"sequence_number": sequence, "pending_sequence_number": account.Sequence}
+		} else {
+			resp.Metadata = map[string]interface{}{"sequence_number": account.Sequence}
+		}
 
 		var needTheta, needTFuel bool
 		if request.Currencies != nil {
@@ -251,3 +294,29 @@ func (s *accountAPIService) AccountCoins(
 	ret, _ := res.(types.AccountCoinsResponse)
 	return &ret, nil
 }
+
+// getSequence returns the committed sequence of the account, 0 if the account does not exist yet.
+func (s *accountAPIService) getSequence(address string) (uint64, error) {
+	rpcRes, rpcErr := s.client.Call("theta.GetAccount", GetAccountArgs{
+		Address: address,
+	})
+
+	if rpcRes != nil && rpcRes.Error != nil && rpcRes.Error.Code == -32000 {
+		return 0, nil
+	}
+
+	parse := func(jsonBytes []byte) (interface{}, error) {
+		account := GetAccountResult{}.Account
+		err := json.Unmarshal(jsonBytes, &account)
+		if err != nil {
+			return nil, err
+		}
+		return account.Sequence, nil
+	}
+
+	res, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse)
+	if err != nil {
+		return 0, err
+	}
+	return res.(uint64), nil
+}
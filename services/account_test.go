@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/spf13/viper"
+	jrpc "github.com/ybbus/jsonrpc"
+
+	cmn "github.com/thetatoken/theta-rosetta-rpc-adaptor/common"
+
+	"github.com/thetatoken/theta/common"
+	ttypes "github.com/thetatoken/theta/ledger/types"
+)
+
+func TestAccountIncludeMempool(t *testing.T) {
+	viper.Set(cmn.CfgRosettaMode, cmn.CfgRosettaModeOnline)
+	defer viper.Set(cmn.CfgRosettaMode, nil)
+	cmn.SetChainIds([]string{testChainID})
+
+	address := common.HexToAddress("0x2e833968e5bb786ae419c4d13189fb081cc43bab")
+	committed := &ttypes.Account{Address: address, Sequence: 3, Balance: ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(100)}}
+	pending := &ttypes.Account{Address: address, Sequence: 5, Balance: ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(40)}}
+
+	s := &accountAPIService{client: &fakeRPCClient{respond: func(method string, args interface{}) (interface{}, *jrpc.RPCError) {
+		switch method {
+		case "theta.GetStatus":
+			return cmn.GetStatusResult{LatestFinalizedBlockHeight: 50}, nil
+		case "theta.GetAccount":
+			accountArgs := args.(GetAccountArgs)
+			if !accountArgs.Preview {
+				return committed, nil
+			}
+			// the pending txs are only projected on top of the latest state
+			if accountArgs.Height != 0 {
+				return nil, &jrpc.RPCError{Code: -32000, Message: "no screened view at a past height"}
+			}
+			return pending, nil
+		}
+		return nil, &jrpc.RPCError{Code: -32601, Message: "unexpected method " + method}
+	}}}
+
+	accountID := &types.AccountIdentifier{Address: address.Hex()}
+	tfuel := []*types.Currency{cmn.GetTFuelCurrency()}
+	index := int64(10)
+
+	tests := []struct {
+		name           string
+		includeMempool bool
+		coins          bool
+		block          *types.PartialBlockIdentifier
+		valid          bool
+		balance        string
+		meta           map[string]interface{}
+	}{
+		{"balance", false, false, nil, true, "100", map[string]interface{}{"sequence_number": uint64(3)}},
+		{"balance with mempool", true, false, nil, true, "40", map[string]interface{}{"sequence_number": uint64(3), "pending_sequence_number": uint64(5)}},
+		{"balance with mempool at a block", true, false, &types.PartialBlockIdentifier{Index: &index}, false, "", nil},
+		{"coins", false, true, nil, true, "100", map[string]interface{}{"sequence_number": uint64(3)}},
+		{"coins with mempool", true, true, nil, true, "40", map[string]interface{}{"sequence_number": uint64(3), "pending_sequence_number": uint64(5)}},
+	}
+	for _, test := range tests {
+		var balance *types.Amount
+		var blockIdentifier *types.BlockIdentifier
+		var meta map[string]interface{}
+		var terr *types.Error
+		if test.coins {
+			var resp *types.AccountCoinsResponse
+			resp, terr = s.AccountCoins(context.Background(), &types.AccountCoinsRequest{
+				NetworkIdentifier: testNetworkIdentifier(),
+				AccountIdentifier: accountID,
+				IncludeMempool:    test.includeMempool,
+				Currencies:        tfuel,
+			})
+			if terr == nil {
+				balance, blockIdentifier, meta = resp.Coins[0].Amount, resp.BlockIdentifier, resp.Metadata
+			}
+		} else {
+			ctx := context.WithValue(context.Background(), requestMetadataKey{}, map[string]interface{}{"include_mempool": test.includeMempool})
+			var resp *types.AccountBalanceResponse
+			resp, terr = s.AccountBalance(ctx, &types.AccountBalanceRequest{
+				NetworkIdentifier: testNetworkIdentifier(),
+				AccountIdentifier: accountID,
+				BlockIdentifier:   test.block,
+				Currencies:        tfuel,
+			})
+			if terr == nil {
+				balance, blockIdentifier, meta = resp.Balances[0], resp.BlockIdentifier, resp.Metadata
+			}
+		}
+
+		if (terr == nil) != test.valid {
+			t.Errorf("%v: expected valid %v, got %v", test.name, test.valid, terr)
+			continue
+		}
+		if terr != nil {
+			continue
+		}
+		if balance.Value != test.balance {
+			t.Errorf("%v: expected balance %v, got %v", test.name, test.balance, balance.Value)
+		}
+		if blockIdentifier.Index != 50 {
+			t.Errorf("%v: expected the latest finalized block, got %v", test.name, blockIdentifier.Index)
+		}
+		if len(meta) != len(test.meta) {
+			t.Errorf("%v: expected metadata %v, got %v", test.name, test.meta, meta)
+			continue
+		}
+		for k, v := range test.meta {
+			if meta[k] != v {
+				t.Errorf("%v: expected %v %v, got %v", test.name, k, v, meta[k])
+			}
+		}
+	}
+}
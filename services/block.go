@@ -151,6 +151,9 @@ func (s *blockAPIService) Block(
 				}
 
 				tx := cmn.ParseTx(tblock.Txs[i].Type, txMap["raw"], tblock.Txs[i].Hash, &status, gasUsed, tblock.Txs[i].BalanceChanges, s.db, s.stakeService, tblock.Height)
+				if tblock.Txs[i].Type == cmn.SmartContractTx {
+					cmn.ParseCrossChainTransfers(&tx, tblock.Txs[i].Receipt, &status)
+				}
 				txs = append(txs, &tx)
 			}
 		}
@@ -222,6 +225,9 @@ func (s *blockAPIService) BlockTransaction(
 			status := string(txResult.Status)
 			if "not_found" != status {
 				tx := cmn.ParseTx(cmn.TxType(txResult.Type), rawTx, txResult.TxHash, &status, gasUsed, txResult.BalanceChanges, nil, nil, 0)
+				if cmn.TxType(txResult.Type) == cmn.SmartContractTx {
+					cmn.ParseCrossChainTransfers(&tx, txResult.Receipt, &status)
+				}
 				resp.Transaction = &tx
 			}
 		}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+
+	cmn "github.com/thetatoken/theta-rosetta-rpc-adaptor/common"
+
+	jrpc "github.com/ybbus/jsonrpc"
+)
+
+// callHandler serves one /call method, given the parameters of the request. It returns the
+// result and whether calling again with the same parameters returns the same result.
+type callHandler func(client jrpc.RPCClient, params map[string]interface{}) (map[string]interface{}, bool, *types.Error)
+
+// callHandlers are the /call methods served, by name.
+var callHandlers = map[string]callHandler{
+	"call_smart_contract": callSmartContract,
+}
+
+// CallMethods returns the names of the /call methods served.
+func CallMethods() []string {
+	methods := make([]string, 0, len(callHandlers))
+	for method := range callHandlers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+type callAPIService struct {
+	client jrpc.RPCClient
+}
+
+// NewCallAPIService creates a new instance of a CallAPIService.
+func NewCallAPIService(client jrpc.RPCClient) server.CallAPIServicer {
+	return &callAPIService{
+		client: client,
+	}
+}
+
+// Call implements the /call endpoint.
+func (s *callAPIService) Call(
+	ctx context.Context,
+	request *types.CallRequest,
+) (*types.CallResponse, *types.Error) {
+	if !strings.EqualFold(cmn.CfgRosettaModeOnline, viper.GetString(cmn.CfgRosettaMode)) {
+		return nil, cmn.ErrUnavailableOffline
+	}
+
+	if err := cmn.ValidateNetworkIdentifier(ctx, request.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+
+	handler, ok := callHandlers[request.Method]
+	if !ok {
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+		terr.Message += fmt.Sprintf("unsupported call method %v", request.Method)
+		return nil, terr
+	}
+
+	result, idempotent, terr := handler(s.client, request.Parameters)
+	if terr != nil {
+		return nil, terr
+	}
+	return &types.CallResponse{
+		Result:     result,
+		Idempotent: idempotent,
+	}, nil
+}
+
+// callSmartContract runs the hex encoded smart contract tx in "sctx_bytes" against the latest
+// state of the node, without broadcasting it.
+func callSmartContract(client jrpc.RPCClient, params map[string]interface{}) (map[string]interface{}, bool, *types.Error) {
+	sctxBytes, ok := params["sctx_bytes"].(string)
+	if !ok || sctxBytes == "" {
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+		terr.Message += "sctx_bytes is required"
+		return nil, false, terr
+	}
+
+	rpcRes, rpcErr := client.Call("theta.CallSmartContract", CallSmartContractArgs{
+		SctxBytes: strings.TrimPrefix(sctxBytes, "0x"),
+	})
+
+	parse := func(jsonBytes []byte) (interface{}, error) {
+		callResult := CallSmartContractResult{}
+		err := json.Unmarshal(jsonBytes, &callResult)
+		if err != nil {
+			return nil, err
+		}
+		return callResult, nil
+	}
+
+	res, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse)
+	if err != nil {
+		terr := cmn.CopyError(cmn.ErrUnableToCall)
+		terr.Message += err.Error()
+		return nil, false, terr
+	}
+
+	// the result depends on the state the call runs against, which changes with every block
+	callResult := res.(CallSmartContractResult)
+	return map[string]interface{}{
+		"vm_return":        callResult.VmReturn,
+		"contract_address": callResult.ContractAddress.Hex(),
+		"gas_used":         uint64(callResult.GasUsed),
+		"vm_error":         callResult.VmError,
+	}, false, nil
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"math/big"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 
@@ -28,8 +29,19 @@ import (
 )
 
 const (
-	CurveType     = "secp256k1"
-	SignatureType = "ecdsa_recovery"
+	CurveType          = "secp256k1"
+	SignatureType      = "ecdsa_recovery"
+	SignatureTypeEcdsa = "ecdsa"
+
+	// Submit wait_for options
+	WaitForAccepted  = "accepted"
+	WaitForIncluded  = "included"
+	WaitForFinalized = "finalized"
+
+	// SubmitStatusSubmitted is the status of a broadcast tx whose wait timed out, it may still land
+	SubmitStatusSubmitted = "submitted"
+
+	submitPollInterval = time.Second
 )
 
 type BroadcastRawTransactionAsyncArgs struct {
@@ -40,14 +52,42 @@ type BroadcastRawTransactionAsyncResult struct {
 	TxHash string `json:"hash"`
 }
 
+type BroadcastRawTransactionArgs struct {
+	TxBytes string `json:"tx_bytes"`
+}
+
+type BroadcastRawTransactionResult struct {
+	TxHash string `json:"hash"`
+	Block  *struct {
+		Height common.JSONUint64 `json:"height"`
+	} `json:"block"`
+}
+
+type CallSmartContractArgs struct {
+	SctxBytes string `json:"sctx_bytes"`
+}
+
+type CallSmartContractResult struct {
+	VmReturn        string            `json:"vm_return"`
+	ContractAddress common.Address    `json:"contract_address"`
+	GasUsed         common.JSONUint64 `json:"gas_used"`
+	VmError         string            `json:"vm_error"`
+}
+
 type constructionAPIService struct {
-	client jrpc.RPCClient
+	client       jrpc.RPCClient
+	chainID      string
+	nonceTracker *cmn.NonceTracker
+	pendingTxs   *cmn.PendingTxCache
 }
 
 // NewConstructionAPIService creates a new instance of an ConstructionAPIService.
-func NewConstructionAPIService(client jrpc.RPCClient) server.ConstructionAPIServicer {
+func NewConstructionAPIService(client jrpc.RPCClient, chainID string, nonceTracker *cmn.NonceTracker, pendingTxs *cmn.PendingTxCache) server.ConstructionAPIServicer {
 	return &constructionAPIService{
-		client: client,
+		client:       client,
+		chainID:      chainID,
+		nonceTracker: nonceTracker,
+		pendingTxs:   pendingTxs,
 	}
 }
 
@@ -61,21 +101,27 @@ func (s *constructionAPIService) ConstructionDerive(
 	}
 
 	if request.PublicKey == nil {
-		terr := cmn.ErrInvalidInputParam
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
 		terr.Message += "public key is not provided"
 		return nil, terr
 	}
 
-	if len(request.PublicKey.Bytes) == 0 || request.PublicKey.CurveType != CurveType {
-		terr := cmn.ErrInvalidInputParam
-		terr.Message += "unsupported public key curve type"
+	if request.PublicKey.CurveType != CurveType {
+		terr := cmn.CopyError(cmn.ErrUnsupportedPublicKeyType)
+		terr.Message += fmt.Sprintf(": unsupported curve type %v", request.PublicKey.CurveType)
+		return nil, terr
+	}
+
+	if len(request.PublicKey.Bytes) == 0 {
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+		terr.Message += "public key is empty"
 		return nil, terr
 	}
 
-	pubkey, err := decompressPubkey(request.PublicKey.Bytes)
+	pubkey, format, err := parsePubkey(request.PublicKey.Bytes)
 	if err != nil {
-		terr := cmn.ErrInvalidInputParam
-		terr.Message += "Unable to decompress public key: " + err.Error()
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+		terr.Message += "Unable to parse public key: " + err.Error()
 		return nil, terr
 	}
 
@@ -84,6 +130,9 @@ func (s *constructionAPIService) ConstructionDerive(
 		AccountIdentifier: &types.AccountIdentifier{
 			Address: addr.Hex(),
 		},
+		Metadata: map[string]interface{}{
+			"public_key_format": format,
+		},
 	}, nil
 }
 
@@ -98,48 +147,128 @@ func (s *constructionAPIService) ConstructionPreprocess(
 
 	options := make(map[string]interface{})
 
-	matches, e := getOperationDescriptions(request.Operations)
-	if e != nil {
-		return nil, e
+	txType := cmn.InferTxType(request.Operations)
+	if typ, ok := request.Metadata["type"]; ok {
+		if txType, ok = cmn.ToTxType(typ); !ok {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += fmt.Sprintf("invalid tx type %v", typ)
+			return nil, terr
+		}
+	}
+	if !cmn.IsConstructionTxType(txType) {
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+		terr.Message += fmt.Sprintf("tx type %v cannot be constructed", txType)
+		return nil, terr
 	}
+	options["type"] = txType
+
+	var tokenData []byte
+
+	switch txType {
+	case cmn.SendTx: // SendTx, possibly with multiple inputs
+		inputs, outputs, fee, err := cmn.GetSendTxInputsOutputs(request.Operations, nil)
+		if err != nil {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += err.Error()
+			return nil, terr
+		}
+
+		signers := make([]string, 0, len(inputs))
+		for _, input := range inputs {
+			signers = append(signers, input.Address.Hex())
+		}
 
-	if len(matches) == 2 {
-		options["type"] = cmn.SmartContractTx
-	} else if len(matches) == 5 { // SendTx
-		fromThetaOp, _ := matches[0].First()
-		fromTFuelOp, _ := matches[1].First()
-		toThetaOp, _ := matches[2].First()
-		toTFuelOp, _ := matches[3].First()
-		feeOp, feeWei := matches[4].First()
+		options["fee"] = fee
+		options["signer"] = signers[0]
+		options["signers"] = signers
+		options["num_accounts"] = len(inputs) + len(outputs)
 
-		if fromThetaOp.Account.Address != fromTFuelOp.Account.Address || fromTFuelOp.Account.Address != feeOp.Account.Address {
-			terr := cmn.ErrServiceInternal
-			terr.Message += "from address not matching"
+	case cmn.SmartContractTx:
+		matches, terr := getOperationDescriptions(request.Operations)
+		if terr != nil {
 			return nil, terr
 		}
 
-		if toThetaOp.Account.Address != toTFuelOp.Account.Address {
-			terr := cmn.ErrServiceInternal
-			terr.Message += "to address not matching"
+		if len(matches) == 1 { // deploying a new contract
+			if data, ok := request.Metadata["data"]; !ok || data == "" {
+				terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+				terr.Message += "missing contract bytecode for deployment"
+				return nil, terr
+			}
+			_, fromAmount := matches[0].First()
+			options["to"] = ""
+			options["value"] = new(big.Int).Mul(fromAmount, big.NewInt(-1)).String()
+		} else {
+			toOp, toAmount := matches[1].First()
+			options["to"] = toOp.Account.Address
+			options["value"] = toAmount.String()
+
+			if token := cmn.GetTokenTransfer(request.Operations); token != nil {
+				if terr := s.checkChainToken(token); terr != nil {
+					return nil, terr
+				}
+				fromOp, fromAmount := matches[0].First()
+				if new(big.Int).Add(fromAmount, toAmount).Sign() != 0 {
+					terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+					terr.Message += "token transfer amounts not matching"
+					return nil, terr
+				}
+				if fromOp.Account.Address == toOp.Account.Address {
+					terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+					terr.Message += "from and to accounts are the same"
+					return nil, terr
+				}
+				options["token_contract"] = token.ContractAddress
+				options["to"] = token.ContractAddress
+				options["value"] = "0"
+				tokenData = cmn.EncodeTnt20Transfer(common.HexToAddress(toOp.Account.Address), toAmount)
+			}
+		}
+
+		fromOp, _ := matches[0].First()
+		options["signer"] = fromOp.Account.Address
+
+	default:
+		if terr := validateFundOperations(txType, request.Operations); terr != nil {
 			return nil, terr
 		}
 
-		if fromTFuelOp.Account.Address == toTFuelOp.Account.Address {
-			terr := cmn.ErrServiceInternal
-			terr.Message += "from and to accounts are the same"
+		// assemble a draft of the tx to validate the operations and find its signers
+		draftMeta := map[string]interface{}{"type": txType, "sequence": 0}
+		for _, key := range cmn.TxMetadataKeys {
+			if v, ok := request.Metadata[key]; ok {
+				draftMeta[key] = v
+			}
+		}
+		tx, err := cmn.AssembleTx(request.Operations, draftMeta)
+		if err != nil {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += err.Error()
 			return nil, terr
 		}
 
-		options["type"] = cmn.SendTx
-		options["fee"] = feeWei
-	} else {
-		err := cmn.ErrServiceInternal
-		err.Message += "invalid number of operations"
-		return nil, err
+		signers := []string{}
+		for _, signer := range cmn.GetTxSigners(tx) {
+			signers = append(signers, signer.Hex())
+		}
+		// the source of a ServicePaymentTx signs without consuming its sequence
+		sequencedSigners := []string{}
+		for _, input := range cmn.GetTxSequencedInputs(tx) {
+			sequencedSigners = append(sequencedSigners, input.Address.Hex())
+		}
+		options["signer"] = sequencedSigners[0]
+		options["signers"] = signers
+		options["sequenced_signers"] = sequencedSigners
+		if fee := getTxFee(tx).TFuelWei; fee.Sign() > 0 {
+			options["fee"] = fee
+		}
 	}
 
-	fromOp, _ := matches[0].First()
-	options["signer"] = fromOp.Account.Address
+	for _, key := range cmn.TxMetadataKeys {
+		if v, ok := request.Metadata[key]; ok {
+			options[key] = v
+		}
+	}
 
 	if gasLimit, ok := request.Metadata["gas_limit"]; ok {
 		options["gas_limit"] = gasLimit
@@ -150,6 +279,40 @@ func (s *constructionAPIService) ConstructionPreprocess(
 	if data, ok := request.Metadata["data"]; ok {
 		options["data"] = data
 	}
+	if usePending, ok := request.Metadata["use_pending_sequence"]; ok {
+		options["use_pending_sequence"] = usePending
+	}
+	if sigType, ok := request.Metadata["signature_type"]; ok {
+		if sigType != SignatureType && sigType != SignatureTypeEcdsa {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += fmt.Sprintf("signature type must be %v or %v", SignatureType, SignatureTypeEcdsa)
+			return nil, terr
+		}
+		options["signature_type"] = sigType
+	}
+	if tokenData != nil {
+		options["data"] = hex.EncodeToString(tokenData)
+	}
+
+	for _, maxFee := range request.MaxFee {
+		if maxFee.Currency == nil || !strings.EqualFold(maxFee.Currency.Symbol, cmn.GetTFuelCurrency().Symbol) {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += "max fee must be specified in TFUEL"
+			return nil, terr
+		}
+		options["max_fee"] = maxFee.Value
+	}
+	if request.SuggestedFeeMultiplier != nil {
+		if *request.SuggestedFeeMultiplier <= 0 {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += "fee multiplier must be positive"
+			return nil, terr
+		}
+		options["fee_multiplier"] = *request.SuggestedFeeMultiplier
+		if _, terr := getFeeMultiplier(options); terr != nil {
+			return nil, terr
+		}
+	}
 
 	return &types.ConstructionPreprocessResponse{
 		Options: options,
@@ -169,102 +332,193 @@ func (s *constructionAPIService) ConstructionMetadata(
 		return nil, err
 	}
 
+	feeMultiplier, terr := getFeeMultiplier(request.Options)
+	if terr != nil {
+		return nil, terr
+	}
+
 	meta := make(map[string]interface{})
 
 	var ok bool
 	var signer interface{}
 	if signer, ok = request.Options["signer"]; !ok {
-		terr := cmn.ErrInvalidInputParam
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
 		terr.Message += "empty signer address"
 		return nil, terr
 	}
 
-	rpcRes, rpcErr := s.client.Call("theta.GetAccount", GetAccountArgs{
-		Address: signer.(string),
-	})
+	usePending, _ := request.Options["use_pending_sequence"].(bool)
 
-	parse := func(jsonBytes []byte) (interface{}, error) {
-		account := GetAccountResult{}.Account
-		err := json.Unmarshal(jsonBytes, &account)
-		if err != nil {
-			return nil, err
-		}
-		return account.Sequence, nil
+	seq, terr := s.getNextSequence(signer.(string), usePending)
+	if terr != nil {
+		return nil, terr
 	}
 
-	seq, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse)
-	if err != nil {
-		return nil, cmn.ErrUnableToGetAccount
-	}
+	meta["sequence"] = seq
 
-	meta["sequence"] = seq.(uint64) + 1
+	// every input of a multi-input SendTx signs with its own sequence. Only the sequences the tx
+	// consumes are reserved, all the signers are sequenced unless listed otherwise.
+	var sequenced map[string]bool
+	if sequencedSigners, ok := request.Options["sequenced_signers"].([]interface{}); ok {
+		sequenced = make(map[string]bool)
+		for _, signer := range sequencedSigners {
+			if addr, ok := signer.(string); ok {
+				sequenced[strings.ToLower(addr)] = true
+			}
+		}
+	}
+	if signers, ok := request.Options["signers"].([]interface{}); ok {
+		sequences := make(map[string]uint64)
+		primary := signer.(string)
+		for _, signer := range signers {
+			addr, ok := signer.(string)
+			if !ok {
+				terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+				terr.Message += "invalid signer address"
+				return nil, terr
+			}
+			// the signer already holds a sequence, a second one would be reserved otherwise
+			if strings.EqualFold(addr, primary) {
+				sequences[common.HexToAddress(addr).Hex()] = seq
+				continue
+			}
+			signerSeq, terr := s.getNextSequence(addr, usePending && (sequenced == nil || sequenced[strings.ToLower(addr)]))
+			if terr != nil {
+				return nil, terr
+			}
+			sequences[common.HexToAddress(addr).Hex()] = signerSeq
+		}
+		meta["sequences"] = sequences
+	}
 
-	var txType interface{}
+	var err error
 
-	if txType, ok = request.Options["type"]; !ok {
-		terr := cmn.ErrInvalidInputParam
+	typ, ok := request.Options["type"]
+	if !ok {
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
 		terr.Message += "tx type missing in metadata"
 		return nil, terr
 	}
+	txType, ok := cmn.ToTxType(typ)
+	if !ok {
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+		terr.Message += fmt.Sprintf("invalid tx type %v", typ)
+		return nil, terr
+	}
 
-	// meta["type"] = txType
+	meta["type"] = txType
+	for _, key := range cmn.TxMetadataKeys {
+		if v, ok := request.Options[key]; ok {
+			meta[key] = v
+		}
+	}
+
+	var maxFee *big.Int
+	if fee, ok := request.Options["max_fee"]; ok {
+		if maxFee, ok = cmn.ToBigInt(fee); !ok || maxFee.Sign() < 0 {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += "invalid max fee"
+			return nil, terr
+		}
+	}
 
 	var status *cmn.GetStatusResult
 	suggestedFee := big.NewInt(0)
 
-	if cmn.TxType(txType.(float64)) == cmn.SendTx {
+	switch txType {
+	case cmn.SendTx:
 		if fee, ok := request.Options["fee"]; ok {
-			suggestedFee = new(big.Int).SetUint64(uint64(fee.(float64)))
+			if suggestedFee, ok = cmn.ToBigInt(fee); !ok || suggestedFee.Sign() < 0 {
+				terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+				terr.Message += "invalid fee"
+				return nil, terr
+			}
 		}
 		if suggestedFee.Cmp(big.NewInt(0)) == 0 {
 			status, err = cmn.GetStatus(s.client)
 			if err != nil {
-				terr := cmn.ErrInvalidInputParam
+				terr := cmn.CopyError(cmn.ErrInvalidInputParam)
 				terr.Message += "can't get blockchain status"
 				return nil, terr
 			}
 			height := uint64(status.CurrentHeight)
-			suggestedFee = ttypes.GetSendTxMinimumTransactionFeeTFuelWei(2, height) // only allow 1-to-1 transfer
-		}
-		meta["fee"] = suggestedFee
-	} else if cmn.TxType(txType.(float64)) == cmn.SmartContractTx {
-		if gasLimit, ok := request.Options["gas_limit"]; ok {
-			meta["gas_limit"] = gasLimit
-		} else {
-			if status == nil {
-				status, err = cmn.GetStatus(s.client)
-				if err != nil {
-					terr := cmn.ErrInvalidInputParam
-					terr.Message += "can't get blockchain status"
+			numAccounts := uint64(2)
+			if num, ok := request.Options["num_accounts"]; ok {
+				if numAccounts, ok = cmn.ToUint64(num); !ok {
+					terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+					terr.Message += "invalid number of accounts"
 					return nil, terr
 				}
 			}
+			suggestedFee = ttypes.GetSendTxMinimumTransactionFeeTFuelWei(numAccounts, height)
+			suggestedFee = multiplyFee(suggestedFee, feeMultiplier)
+		}
+		meta["fee"] = suggestedFee
+	case cmn.SmartContractTx:
+		status, err = cmn.GetStatus(s.client)
+		if err != nil {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += "can't get blockchain status"
+			return nil, terr
+		}
+		height := uint64(status.CurrentHeight)
 
-			height := uint64(status.CurrentHeight)
-			gasLimit = ttypes.GetMaxGasLimit(height).Uint64()
-			meta["gas_limit"] = gasLimit
+		gasPrice := multiplyFee(ttypes.GetMinimumGasPrice(height), feeMultiplier)
+		if price, ok := request.Options["gas_price"]; ok {
+			if gasPrice, ok = cmn.ToBigInt(price); !ok || gasPrice.Sign() < 0 {
+				return nil, cmn.ErrInvalidGasPrice
+			}
 		}
+		meta["gas_price"] = gasPrice
 
-		if gasPrice, ok := request.Options["gas_price"]; ok {
-			meta["gas_price"] = gasPrice
+		var gasLimit uint64
+		if limit, ok := request.Options["gas_limit"]; ok {
+			if gasLimit, ok = cmn.ToUint64(limit); !ok {
+				terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+				terr.Message += "invalid gas limit"
+				return nil, terr
+			}
 		} else {
-			if status == nil {
-				status, err = cmn.GetStatus(s.client)
-				if err != nil {
-					terr := cmn.ErrInvalidInputParam
-					terr.Message += "can't get blockchain status"
-					return nil, terr
-				}
+			var terr *types.Error
+			gasLimit, terr = s.estimateGas(request.Options, meta["sequence"].(uint64), gasPrice, height)
+			if terr != nil {
+				return nil, terr
 			}
-
-			height := uint64(status.CurrentHeight)
-			gasPrice = ttypes.GetMinimumGasPrice(height).Uint64()
-			meta["gas_price"] = gasPrice
 		}
+		meta["gas_limit"] = gasLimit
 
 		if data, ok := request.Options["data"]; ok {
 			meta["data"] = data
 		}
+
+		suggestedFee = new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(gasLimit))
+	default:
+		if fee, ok := request.Options["fee"]; ok {
+			if suggestedFee, ok = cmn.ToBigInt(fee); !ok || suggestedFee.Sign() < 0 {
+				terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+				terr.Message += "invalid fee"
+				return nil, terr
+			}
+		} else {
+			status, err = cmn.GetStatus(s.client)
+			if err != nil {
+				terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+				terr.Message += "can't get blockchain status"
+				return nil, terr
+			}
+			suggestedFee = multiplyFee(ttypes.GetMinimumTransactionFeeTFuelWei(uint64(status.CurrentHeight)), feeMultiplier)
+		}
+		meta["fee"] = suggestedFee
+	}
+
+	if sigType, ok := request.Options["signature_type"]; ok {
+		meta["signature_type"] = sigType
+	}
+
+	if maxFee != nil && suggestedFee.Cmp(maxFee) > 0 {
+		terr := cmn.CopyError(cmn.ErrExceededFee)
+		terr.Message += fmt.Sprintf(": fee %v exceeds max fee %v", suggestedFee, maxFee)
+		return nil, terr
 	}
 
 	return &types.ConstructionMetadataResponse{
@@ -287,151 +541,92 @@ func (s *constructionAPIService) ConstructionPayloads(
 		return nil, err
 	}
 
-	var ok bool
-	var err error
-	var seq interface{}
-	if seq, ok = request.Metadata["sequence"]; !ok {
-		terr := cmn.ErrServiceInternal
-		terr.Message += "missing tx sequence"
-		return nil, terr
+	meta := make(map[string]interface{}, len(request.Metadata)+1)
+	for key, v := range request.Metadata {
+		meta[key] = v
 	}
-	sequence := uint64(seq.(float64))
-
-	var tx ttypes.Tx
-
-	matches, e := getOperationDescriptions(request.Operations)
-	if e != nil {
-		return nil, e
+	if _, ok := meta["type"]; !ok {
+		meta["type"] = cmn.InferTxType(request.Operations)
 	}
 
-	if len(request.Operations) == 2 { // SmartContractTx
-		fromOp, fromTFuelWei := matches[0].First()
-		fromTFuelWei = new(big.Int).Mul(fromTFuelWei, big.NewInt(-1))
-		from := ttypes.TxInput{
-			Address: common.HexToAddress(fromOp.Account.Address),
-			Coins: ttypes.Coins{
-				ThetaWei: new(big.Int).SetUint64(0),
-				TFuelWei: fromTFuelWei,
-			},
-			Sequence: sequence,
+	if txType, ok := cmn.ToTxType(meta["type"]); ok {
+		if txType == cmn.SmartContractTx {
+			if _, terr := getOperationDescriptions(request.Operations); terr != nil {
+				return nil, terr
+			}
+			if token := cmn.GetTokenTransfer(request.Operations); token != nil {
+				if terr := s.checkChainToken(token); terr != nil {
+					return nil, terr
+				}
+			}
+		} else if terr := validateFundOperations(txType, request.Operations); terr != nil {
+			return nil, terr
 		}
+	}
 
-		toOp, toTFuelWei := matches[1].First()
-		to := ttypes.TxOutput{
-			Address: common.HexToAddress(toOp.Account.Address),
-			Coins: ttypes.Coins{
-				ThetaWei: new(big.Int).SetUint64(0),
-				TFuelWei: toTFuelWei,
-			},
-		}
+	tx, err := cmn.AssembleTx(request.Operations, meta)
+	if err != nil {
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+		terr.Message += err.Error()
+		return nil, terr
+	}
 
-		gasPriceStr := "0wei"
-		if gasPrice, ok := request.Metadata["gas_price"]; ok {
-			gasPriceStr = fmt.Sprintf("%f", gasPrice.(float64)) + "wei"
-		}
-		gasPrice, ok := ttypes.ParseCoinAmount(gasPriceStr)
+	// the target of a service payment signs the tx carrying the source signature, so the source
+	// is asked to sign first, and the target payload is only returned once the source signature
+	// is passed back as source_signature
+	signers := cmn.GetTxSigners(tx)
+	if servicePaymentTx, ok := tx.(*ttypes.ServicePaymentTx); ok {
+		sourceSig, ok := request.Metadata["source_signature"].(string)
 		if !ok {
-			terr := cmn.ErrServiceInternal
-			terr.Message += "failed to parse gas price"
-			return nil, terr
-		}
-
-		gasLimit := uint64(10000000) //TODO: 10000000 or 0?
-		if gasLim, ok := request.Metadata["gas_limit"]; ok {
-			gasLimit = uint64(gasLim.(float64))
+			signers = signers[:1]
+		} else {
+			sigBytes, err := hex.DecodeString(strings.TrimPrefix(sourceSig, "0x"))
 			if err != nil {
-				terr := cmn.ErrServiceInternal
-				terr.Message += "failed to parse gas limit"
+				terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+				terr.Message += "invalid source signature: " + err.Error()
 				return nil, terr
 			}
+			sig, err := crypto.SignatureFromBytes(sigBytes)
+			source := servicePaymentTx.Source.Address
+			if err != nil || !sig.Verify(cmn.GetTxSignBytes(tx, source, s.chainID), source) {
+				terr := cmn.CopyError(cmn.ErrInvalidSignature)
+				terr.Message += fmt.Sprintf(": invalid source signature for %v", source.Hex())
+				return nil, terr
+			}
+			servicePaymentTx.SetSourceSignature(sig)
+			signers = signers[1:]
 		}
-
-		var dataStr string
-		if datum, ok := request.Metadata["data"]; ok {
-			dataStr = datum.(string)
-		}
-		data, err := hex.DecodeString(dataStr)
-		if err != nil {
-			terr := cmn.ErrServiceInternal
-			terr.Message += "failed to parse data"
-			return nil, terr
-		}
-
-		tx = &ttypes.SmartContractTx{
-			From:     from,
-			To:       to,
-			GasLimit: gasLimit, //uint64(meta["gas_limit"].(float64)),
-			GasPrice: gasPrice, //big.NewInt(int64(meta["gas_price"].(float64))),
-			Data:     data,     //request.Metadata["data"].([]byte),
-		}
-
-	} else if len(request.Operations) == 5 { // SendTx
-		fromThetaOp, fromThetaWei := matches[0].First()
-		_, fromTFuelWei := matches[1].First()
-		toThetaOp, toThetaWei := matches[2].First()
-		_, toTFuelWei := matches[3].First()
-		_, feeWei := matches[4].First() // TODO: overwritten with request.Metadata["fee"] ?
-
-		fromThetaWei = new(big.Int).Mul(fromThetaWei, big.NewInt(-1))
-		fromTFuelWei = new(big.Int).Mul(fromTFuelWei, big.NewInt(-1))
-		feeWei = new(big.Int).Mul(feeWei, big.NewInt(-1))
-
-		inputs := []ttypes.TxInput{
-			{
-				Address: common.HexToAddress(fromThetaOp.Account.Address),
-				Coins: ttypes.Coins{
-					ThetaWei: fromThetaWei,
-					TFuelWei: new(big.Int).Add(fromTFuelWei, feeWei),
-				},
-				Sequence: uint64(seq.(float64)),
-			},
-		}
-
-		outputs := []ttypes.TxOutput{
-			{
-				Address: common.HexToAddress(toThetaOp.Account.Address),
-				Coins: ttypes.Coins{
-					ThetaWei: toThetaWei,
-					TFuelWei: toTFuelWei,
-				},
-			},
-		}
-
-		tx = &ttypes.SendTx{
-			Fee: ttypes.Coins{
-				ThetaWei: new(big.Int).SetUint64(0),
-				TFuelWei: feeWei,
-			},
-			Inputs:  inputs,
-			Outputs: outputs,
-		}
-	} else {
-		err := cmn.ErrServiceInternal
-		err.Message += "invalid number of operations"
-		return nil, err
 	}
 
 	raw, err := ttypes.TxToBytes(tx)
 	if err != nil {
-		terr := cmn.ErrServiceInternal
+		terr := cmn.CopyError(cmn.ErrServiceInternal)
 		terr.Message += err.Error()
 		return nil, terr
 	}
 
 	unsignedTx := hex.EncodeToString(raw)
-	signBytes := tx.SignBytes(cmn.GetChainId())
+
+	signatureType := types.SignatureType(SignatureType)
+	if sigType, ok := request.Metadata["signature_type"].(string); ok {
+		signatureType = types.SignatureType(sigType)
+	}
+
+	// one payload per unique input account
+	payloads := []*types.SigningPayload{}
+	for _, signer := range signers {
+		payloads = append(payloads, &types.SigningPayload{
+			AccountIdentifier: &types.AccountIdentifier{
+				Address: signer.Hex(),
+			},
+			Bytes:         crypto.Keccak256Hash(cmn.GetTxSignBytes(tx, signer, s.chainID)).Bytes(),
+			SignatureType: signatureType,
+		})
+	}
 
 	return &types.ConstructionPayloadsResponse{
 		UnsignedTransaction: unsignedTx,
-		Payloads: []*types.SigningPayload{
-			{
-				AccountIdentifier: &types.AccountIdentifier{
-					Address: request.Operations[0].Account.Address,
-				},
-				Bytes:         crypto.Keccak256Hash(signBytes).Bytes(),
-				SignatureType: SignatureType,
-			},
-		},
+		Payloads:            payloads,
 	}, nil
 }
 
@@ -446,34 +641,22 @@ func (s *constructionAPIService) ConstructionParse(
 
 	rawTx, err := hex.DecodeString(request.Transaction)
 	if err != nil {
-		terr := cmn.ErrUnableToParseTx
+		terr := cmn.CopyError(cmn.ErrUnableToParseTx)
 		terr.Message += err.Error()
 		return nil, terr
 	}
 
 	tx, err := ttypes.TxFromBytes(rawTx)
 	if err != nil {
-		terr := cmn.ErrUnableToParseTx
+		terr := cmn.CopyError(cmn.ErrUnableToParseTx)
 		terr.Message += err.Error()
 		return nil, terr
 	}
 
-	var signer string
-	var meta map[string]interface{}
-	var ops []*types.Operation
-
-	switch tx.(type) {
-	case *ttypes.SendTx:
-		tran := *tx.(*ttypes.SendTx)
-		signer = tran.Inputs[0].Address.String()
-		meta, ops = cmn.ParseSendTx(tran, nil, cmn.SendTx)
-	case *ttypes.SmartContractTx:
-		tran := *tx.(*ttypes.SmartContractTx)
-		signer = tran.From.Address.String()
-		meta, ops = cmn.ParseSmartContractTxForConstruction(tran, cmn.SmartContractTx)
-	default:
-		terr := cmn.ErrUnableToParseTx
-		terr.Message += "unsupported tx type"
+	meta, ops, err := cmn.ParseTxForConstruction(tx)
+	if err != nil {
+		terr := cmn.CopyError(cmn.ErrUnableToParseTx)
+		terr.Message += err.Error()
 		return nil, terr
 	}
 
@@ -482,10 +665,10 @@ func (s *constructionAPIService) ConstructionParse(
 		Metadata:   meta,
 	}
 	if request.Signed {
-		resp.AccountIdentifierSigners = []*types.AccountIdentifier{
-			{
-				Address: signer,
-			},
+		for _, signer := range cmn.GetTxSigners(tx) {
+			resp.AccountIdentifierSigners = append(resp.AccountIdentifierSigners, &types.AccountIdentifier{
+				Address: signer.Hex(),
+			})
 		}
 	}
 
@@ -503,58 +686,73 @@ func (s *constructionAPIService) ConstructionCombine(
 
 	rawTx, err := hex.DecodeString(request.UnsignedTransaction)
 	if err != nil {
-		terr := cmn.ErrUnableToParseTx
+		terr := cmn.CopyError(cmn.ErrUnableToParseTx)
 		terr.Message += err.Error()
 		return nil, terr
 	}
 
 	tx, err := ttypes.TxFromBytes(rawTx)
 	if err != nil {
-		terr := cmn.ErrUnableToParseTx
+		terr := cmn.CopyError(cmn.ErrUnableToParseTx)
 		terr.Message += err.Error()
 		return nil, terr
 	}
 
-	if len(request.Signatures) != 1 {
-		terr := cmn.ErrInvalidInputParam
-		terr.Message += "need exact 1 signature"
-		return nil, terr
+	// the inputs signed already, like the source of a service payment, need no signature
+	signers := []common.Address{}
+	for _, input := range cmn.GetTxInputs(tx) {
+		if input.Signature == nil || input.Signature.IsEmpty() {
+			signers = append(signers, input.Address)
+		}
 	}
-
-	sig, err := crypto.SignatureFromBytes(request.Signatures[0].Bytes)
-	if err != nil {
-		terr := cmn.ErrInvalidInputParam
-		terr.Message += fmt.Sprintf("Cannot convert signature from payload bytes")
+	if len(request.Signatures) != len(signers) {
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+		terr.Message += fmt.Sprintf("need exact %d signature(s)", len(signers))
 		return nil, terr
 	}
 
-	signer := common.HexToAddress(request.Signatures[0].SigningPayload.AccountIdentifier.Address)
-
-	// Check signatures
-	signBytes := tx.SignBytes(cmn.GetChainId())
+	signatures := make(map[common.Address]*types.Signature)
+	for _, signature := range request.Signatures {
+		if signature.SigningPayload == nil || signature.SigningPayload.AccountIdentifier == nil {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += "missing signing payload account"
+			return nil, terr
+		}
+		signer := common.HexToAddress(signature.SigningPayload.AccountIdentifier.Address)
 
-	if !sig.Verify(signBytes, signer) {
-		terr := cmn.ErrInvalidInputParam
-		terr.Message += fmt.Sprintf("Signature verification failed, SignBytes: %v", hex.EncodeToString(signBytes))
-		return nil, terr
+		if _, ok := signatures[signer]; ok {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += fmt.Sprintf("duplicate signature for %v", signer.Hex())
+			return nil, terr
+		}
+		signatures[signer] = signature
 	}
 
-	switch tx.(type) {
-	case *ttypes.SendTx:
-		tx.(*ttypes.SendTx).SetSignature(signer, sig)
-		// tran := *tx.(*ttypes.SendTx)
-		// tran.SetSignature(signer, sig)
-	case *ttypes.SmartContractTx:
-		tx.(*ttypes.SmartContractTx).SetSignature(signer, sig)
-	default:
-		terr := cmn.ErrUnableToParseTx
-		terr.Message += "unsupported tx type"
-		return nil, terr
+	// signatures are set in input order, the sign bytes of an input may cover the signatures
+	// of the inputs before it
+	for _, signer := range signers {
+		signature, ok := signatures[signer]
+		if !ok {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += fmt.Sprintf("missing signature for %v", signer.Hex())
+			return nil, terr
+		}
+
+		sig, terr := parseSignature(signature, cmn.GetTxSignBytes(tx, signer, s.chainID), signer)
+		if terr != nil {
+			return nil, terr
+		}
+
+		if !cmn.SetTxSignature(tx, signer, sig) {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += fmt.Sprintf("%v is not a signer of the transaction", signer.Hex())
+			return nil, terr
+		}
 	}
 
 	raw, err := ttypes.TxToBytes(tx)
 	if err != nil {
-		terr := cmn.ErrInvalidInputParam
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
 		terr.Message += "Failed to encode transaction"
 		return nil, terr
 	}
@@ -575,12 +773,20 @@ func (s *constructionAPIService) ConstructionHash(
 
 	rawTx, err := hex.DecodeString(request.SignedTransaction)
 	if err != nil {
-		terr := cmn.ErrInvalidInputParam
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
 		terr.Message += "invalid signed transaction format: " + err.Error()
 		return nil, terr
 	}
 
-	hash := crypto.Keccak256Hash(rawTx)
+	// an Ethereum-style raw tx would be hashed by the node from its translated form, only txs
+	// in the Theta encoding, the ones built by this adaptor, are hashed locally
+	if _, err := ttypes.TxFromBytes(rawTx); err != nil {
+		terr := cmn.CopyError(cmn.ErrUnableToParseTx)
+		terr.Message += err.Error()
+		return nil, terr
+	}
+
+	hash := cmn.TxHash(rawTx)
 
 	return &types.TransactionIdentifierResponse{
 		TransactionIdentifier: &types.TransactionIdentifier{
@@ -602,35 +808,595 @@ func (s *constructionAPIService) ConstructionSubmit(
 		return nil, err
 	}
 
-	rpcRes, rpcErr := s.client.Call("theta.BroadcastRawTransactionAsync", BroadcastRawTransactionAsyncArgs{
-		TxBytes: request.SignedTransaction,
-	})
+	meta := getRequestMetadata(ctx)
 
-	parse := func(jsonBytes []byte) (interface{}, error) {
-		broadcastResult := BroadcastRawTransactionAsyncResult{}
-		err := json.Unmarshal(jsonBytes, &broadcastResult)
-		if err != nil {
-			return nil, err
-		}
+	waitFor, _ := meta["wait_for"].(string)
+	if waitFor != "" && waitFor != WaitForAccepted && waitFor != WaitForIncluded && waitFor != WaitForFinalized {
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+		terr.Message += "wait_for must be one of accepted, included or finalized"
+		return nil, terr
+	}
 
-		resp := types.TransactionIdentifierResponse{}
-		resp.TransactionIdentifier = &types.TransactionIdentifier{
-			Hash: broadcastResult.TxHash,
+	timeout := time.Duration(viper.GetInt64(cmn.CfgRosettaSubmitTimeoutSecs)) * time.Second
+	if t, ok := meta["timeout"]; ok {
+		secs, ok := cmn.ToUint64(t)
+		if !ok || secs == 0 {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += "invalid timeout"
+			return nil, terr
 		}
-		return resp, nil
+		timeout = time.Duration(secs) * time.Second
 	}
 
-	res, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse)
-	if err != nil {
-		terr := cmn.ErrUnableToSubmitTx
-		terr.Message += err.Error()
+	tx, terr := s.validateTx(request.SignedTransaction)
+	if terr != nil {
 		return nil, terr
 	}
 
-	ret, _ := res.(types.TransactionIdentifierResponse)
+	if dryRun, _ := meta["dry_run"].(bool); dryRun {
+		rawTx, _ := hex.DecodeString(request.SignedTransaction)
+		return &types.TransactionIdentifierResponse{
+			TransactionIdentifier: &types.TransactionIdentifier{
+				Hash: cmn.TxHash(rawTx).String(),
+			},
+			Metadata: map[string]interface{}{
+				"dry_run": true,
+			},
+		}, nil
+	}
+
+	var ret *types.TransactionIdentifierResponse
+	if waitFor == WaitForIncluded {
+		rawTx, _ := hex.DecodeString(request.SignedTransaction)
+		ret, terr = s.broadcastSync(request.SignedTransaction, cmn.TxHash(rawTx).Hex(), timeout)
+	} else {
+		ret, terr = s.broadcastAsync(request.SignedTransaction)
+	}
+	if terr != nil {
+		return nil, terr
+	}
+
+	// /construction/hash and /block report the computed hash, so a node hash that differs from
+	// it could never be matched against them
+	rawTx, _ := hex.DecodeString(request.SignedTransaction)
+	if hash := cmn.TxHash(rawTx); !strings.EqualFold(hash.Hex(), ret.TransactionIdentifier.Hash) {
+		logger.Errorf("Tx hash mismatch, node: %v, computed: %v", ret.TransactionIdentifier.Hash, hash.Hex())
+		terr := cmn.CopyError(cmn.ErrTxHashMismatch)
+		terr.Message += fmt.Sprintf(": node: %v, computed: %v", ret.TransactionIdentifier.Hash, hash.Hex())
+		return nil, terr
+	}
+
+	// reserve the sequences of the submitted tx until it lands or drops
+	for _, input := range cmn.GetTxSequencedInputs(tx) {
+		s.nonceTracker.Reserve(input.Address, input.Sequence, ret.TransactionIdentifier.Hash)
+	}
+
+	// keep the raw tx so that /mempool/transaction can decode it while pending
+	s.pendingTxs.Add(ret.TransactionIdentifier.Hash, rawTx)
+
+	if waitFor == WaitForAccepted || waitFor == WaitForFinalized {
+		waitMeta, terr := s.waitForTx(ret.TransactionIdentifier.Hash, waitFor, timeout)
+		if terr != nil {
+			return nil, terr
+		}
+		if ret.Metadata == nil {
+			ret.Metadata = make(map[string]interface{})
+		}
+		for key, v := range waitMeta {
+			ret.Metadata[key] = v
+		}
+	}
+
+	return ret, nil
+}
+
+// parseSignature converts a Rosetta signature into a Theta signature verified against the
+// signer. A 64-byte r||s signature carries no recovery id, so both ids are tried and the one
+// recovering to the signer is kept.
+func parseSignature(signature *types.Signature, signBytes []byte, signer common.Address) (*crypto.Signature, *types.Error) {
+	var candidates [][]byte
+	switch len(signature.Bytes) {
+	case 65:
+		candidates = [][]byte{signature.Bytes}
+	case 64:
+		if signature.PublicKey == nil {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += "public key is required for a 64-byte signature"
+			return nil, terr
+		}
+		pubkey, _, err := parsePubkey(signature.PublicKey.Bytes)
+		if err != nil || pubkeyToAddress(*pubkey) != signer {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += fmt.Sprintf("public key does not match signer %v", signer.Hex())
+			return nil, terr
+		}
+		for _, v := range []byte{0, 1} {
+			candidates = append(candidates, append(append([]byte{}, signature.Bytes...), v))
+		}
+	default:
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+		terr.Message += fmt.Sprintf("invalid signature length %d", len(signature.Bytes))
+		return nil, terr
+	}
+
+	for _, sigBytes := range candidates {
+		sig, err := crypto.SignatureFromBytes(sigBytes)
+		if err != nil {
+			continue
+		}
+		if sig.Verify(signBytes, signer) {
+			return sig, nil
+		}
+	}
+
+	terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+	terr.Message += fmt.Sprintf("Signature verification failed, SignBytes: %v", hex.EncodeToString(signBytes))
+	return nil, terr
+}
+
+// validateTx decodes the signed tx and checks its signatures, sequences, balances and fees
+// against the node so that a doomed tx is rejected before it is broadcast.
+func (s *constructionAPIService) validateTx(signedTx string) (ttypes.Tx, *types.Error) {
+	rawTx, err := hex.DecodeString(signedTx)
+	if err != nil {
+		terr := cmn.CopyError(cmn.ErrUnableToParseTx)
+		terr.Message += err.Error()
+		return nil, terr
+	}
+
+	tx, err := ttypes.TxFromBytes(rawTx)
+	if err != nil {
+		terr := cmn.CopyError(cmn.ErrUnableToParseTx)
+		terr.Message += err.Error()
+		return nil, terr
+	}
+
+	inputs := cmn.GetTxInputs(tx)
+	if len(inputs) == 0 {
+		terr := cmn.CopyError(cmn.ErrUnableToParseTx)
+		terr.Message += "unsupported tx type"
+		return nil, terr
+	}
+	for _, input := range inputs {
+		signBytes := cmn.GetTxSignBytes(tx, input.Address, s.chainID)
+		if input.Signature == nil || !input.Signature.Verify(signBytes, input.Address) {
+			terr := cmn.CopyError(cmn.ErrInvalidSignature)
+			terr.Message += fmt.Sprintf(": invalid signature for %v", input.Address.Hex())
+			return nil, terr
+		}
+	}
+
+	status, err := cmn.GetStatus(s.client)
+	if err != nil {
+		return nil, cmn.ErrUnableToGetNodeStatus
+	}
+	// the minimum fees are taken at the current height, as the node checks them. A tx priced by
+	// /construction/metadata just before the minimum is raised at a fork height is rejected here,
+	// as it would be by the node, and has to be priced again.
+	height := uint64(status.CurrentHeight)
+
+	// coins each sequenced input has to cover, fees included
+	inputs = cmn.GetTxSequencedInputs(tx)
+	required := make([]ttypes.Coins, len(inputs))
+	switch tx := tx.(type) {
+	case *ttypes.SendTx:
+		minFee := ttypes.GetSendTxMinimumTransactionFeeTFuelWei(uint64(len(tx.Inputs)+len(tx.Outputs)), height)
+		if tx.Fee.TFuelWei == nil || tx.Fee.TFuelWei.Cmp(minFee) < 0 {
+			terr := cmn.CopyError(cmn.ErrInsufficientFee)
+			terr.Message += fmt.Sprintf(": fee %v is below the minimum %v", tx.Fee.TFuelWei, minFee)
+			return nil, terr
+		}
+		for i, input := range tx.Inputs {
+			required[i] = input.Coins
+		}
+	case *ttypes.SmartContractTx:
+		minGasPrice := ttypes.GetMinimumGasPrice(height)
+		if tx.GasPrice == nil || tx.GasPrice.Cmp(minGasPrice) < 0 {
+			terr := cmn.CopyError(cmn.ErrInsufficientFee)
+			terr.Message += fmt.Sprintf(": gas price %v is below the minimum %v", tx.GasPrice, minGasPrice)
+			return nil, terr
+		}
+		gasFee := new(big.Int).Mul(new(big.Int).SetUint64(tx.GasLimit), tx.GasPrice)
+		required[0] = tx.From.Coins.NoNil().Plus(ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: gasFee})
+	case *ttypes.SplitRuleTx, *ttypes.ReserveFundTx, *ttypes.DepositStakeTx, *ttypes.DepositStakeTxV2:
+		// the first input pays the fee and any coins it carries
+		required[0] = inputs[0].Coins.NoNil().Plus(getTxFee(tx))
+	default:
+		// only the fee is spent, by the first sequenced input
+		required[0] = getTxFee(tx)
+	}
+
+	for i, input := range inputs {
+		account, terr := s.getAccount(input.Address.Hex(), true)
+		if terr != nil {
+			return nil, terr
+		}
+		if input.Sequence != account.Sequence+1 {
+			terr := cmn.CopyError(cmn.ErrInvalidTxSequence)
+			terr.Message += fmt.Sprintf(": %v expects sequence %v, got %v", input.Address.Hex(), account.Sequence+1, input.Sequence)
+			return nil, terr
+		}
+		if !account.Balance.IsGTE(required[i].NoNil()) {
+			terr := cmn.CopyError(cmn.ErrInsufficientBalance)
+			terr.Message += fmt.Sprintf(": %v has %v, needs %v", input.Address.Hex(), account.Balance, required[i])
+			return nil, terr
+		}
+	}
+
+	return tx, nil
+}
+
+// broadcastAsync submits the tx and returns as soon as the node received it.
+func (s *constructionAPIService) broadcastAsync(signedTx string) (*types.TransactionIdentifierResponse, *types.Error) {
+	rpcRes, rpcErr := s.client.Call("theta.BroadcastRawTransactionAsync", BroadcastRawTransactionAsyncArgs{
+		TxBytes: signedTx,
+	})
+
+	parse := func(jsonBytes []byte) (interface{}, error) {
+		broadcastResult := BroadcastRawTransactionAsyncResult{}
+		err := json.Unmarshal(jsonBytes, &broadcastResult)
+		if err != nil {
+			return nil, err
+		}
+
+		resp := types.TransactionIdentifierResponse{}
+		resp.TransactionIdentifier = &types.TransactionIdentifier{
+			Hash: broadcastResult.TxHash,
+		}
+		return resp, nil
+	}
+
+	res, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse)
+	if err != nil {
+		return nil, mapSubmitError(err)
+	}
+
+	ret, _ := res.(types.TransactionIdentifierResponse)
 	return &ret, nil
 }
 
+// broadcastSync submits the tx through the synchronous broadcast RPC, which returns once
+// the tx is included in a block. When the timeout fires first, the broadcast may still land,
+// so the locally computed tx hash is returned with the submitted status.
+func (s *constructionAPIService) broadcastSync(signedTx string, txHash string, timeout time.Duration) (*types.TransactionIdentifierResponse, *types.Error) {
+	type callResult struct {
+		rpcRes *jrpc.RPCResponse
+		rpcErr error
+	}
+	done := make(chan callResult, 1)
+	go func() {
+		rpcRes, rpcErr := s.client.Call("theta.BroadcastRawTransaction", BroadcastRawTransactionArgs{
+			TxBytes: signedTx,
+		})
+		done <- callResult{rpcRes, rpcErr}
+	}()
+
+	var rpcRes *jrpc.RPCResponse
+	var rpcErr error
+	select {
+	case res := <-done:
+		rpcRes, rpcErr = res.rpcRes, res.rpcErr
+	case <-time.After(timeout):
+		return &types.TransactionIdentifierResponse{
+			TransactionIdentifier: &types.TransactionIdentifier{
+				Hash: txHash,
+			},
+			Metadata: map[string]interface{}{
+				"status": SubmitStatusSubmitted,
+			},
+		}, nil
+	}
+
+	parse := func(jsonBytes []byte) (interface{}, error) {
+		broadcastResult := BroadcastRawTransactionResult{}
+		err := json.Unmarshal(jsonBytes, &broadcastResult)
+		if err != nil {
+			return nil, err
+		}
+		return broadcastResult, nil
+	}
+
+	res, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse)
+	if err != nil {
+		return nil, mapSubmitError(err)
+	}
+	broadcastResult := res.(BroadcastRawTransactionResult)
+
+	ret := &types.TransactionIdentifierResponse{
+		TransactionIdentifier: &types.TransactionIdentifier{
+			Hash: broadcastResult.TxHash,
+		},
+		Metadata: map[string]interface{}{
+			"status": WaitForIncluded,
+		},
+	}
+	if broadcastResult.Block != nil {
+		blk, terr := cmn.GetBlockIdentifierByHeight(s.client, broadcastResult.Block.Height)
+		if terr == nil {
+			ret.Metadata["block_identifier"] = &types.BlockIdentifier{Index: int64(blk.Height), Hash: blk.Hash.Hex()}
+		}
+	}
+	return ret, nil
+}
+
+// waitForTx polls the node until the tx is accepted into the mempool or finalized. The tx is
+// already broadcast, so a timeout reports the last status seen rather than an error.
+func (s *constructionAPIService) waitForTx(txHash string, waitFor string, timeout time.Duration) (map[string]interface{}, *types.Error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		rpcRes, rpcErr := s.client.Call("theta.GetTransaction", GetTransactionArgs{
+			Hash: txHash,
+		})
+
+		parse := func(jsonBytes []byte) (interface{}, error) {
+			txResult := GetTransactionResult{}
+			err := json.Unmarshal(jsonBytes, &txResult)
+			if err != nil {
+				return nil, err
+			}
+			return txResult, nil
+		}
+
+		res, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse)
+		if err == nil {
+			txResult := res.(GetTransactionResult)
+			switch txResult.Status {
+			case cmn.TxStatusAbandoned:
+				s.nonceTracker.Release(txHash)
+				s.pendingTxs.Remove(txHash)
+				return nil, cmn.ErrTxAbandoned
+			case cmn.TxStatusFinalized:
+				return map[string]interface{}{
+					"status":           string(txResult.Status),
+					"block_identifier": &types.BlockIdentifier{Index: int64(txResult.BlockHeight), Hash: txResult.BlockHash.Hex()},
+				}, nil
+			case cmn.TxStatusPending:
+				if waitFor == WaitForAccepted {
+					return map[string]interface{}{"status": string(txResult.Status)}, nil
+				}
+			}
+		}
+
+		if time.Now().After(deadline) {
+			status := SubmitStatusSubmitted
+			if err == nil && res.(GetTransactionResult).Status == cmn.TxStatusPending {
+				status = string(cmn.TxStatusPending)
+			}
+			return map[string]interface{}{"status": status}, nil
+		}
+		time.Sleep(submitPollInterval)
+	}
+}
+
+// mapSubmitError maps the rejection reason returned by the node to a Rosetta error.
+func mapSubmitError(err error) *types.Error {
+	reason := strings.ToLower(err.Error())
+	var terr *types.Error
+	switch {
+	case strings.Contains(reason, "already seen") || strings.Contains(reason, "already exists"):
+		terr = cmn.ErrDuplicateTx
+	case strings.Contains(reason, "sequence") || strings.Contains(reason, "validateinputadvanced"):
+		terr = cmn.ErrInvalidTxSequence
+	case strings.Contains(reason, "signature"):
+		terr = cmn.ErrInvalidSignature
+	case strings.Contains(reason, "insufficient fund") || strings.Contains(reason, "insufficient balance") || strings.Contains(reason, "not enough"):
+		terr = cmn.ErrInsufficientBalance
+	case strings.Contains(reason, "fee") || strings.Contains(reason, "gas price"):
+		terr = cmn.ErrInsufficientFee
+	default:
+		terr = cmn.ErrUnableToSubmitTx
+	}
+	return &types.Error{
+		Code:      terr.Code,
+		Message:   terr.Message,
+		Retriable: terr.Retriable,
+		Details:   map[string]interface{}{"reason": err.Error()},
+	}
+}
+
+// getNextSequence returns the sequence for the next tx of the account. With usePending, the
+// pending txs in the node's mempool and the txs built or submitted through the adaptor are
+// skipped over, and the returned sequence is reserved for the tx being built.
+func (s *constructionAPIService) getNextSequence(address string, usePending bool) (uint64, *types.Error) {
+	committed, terr := s.getSequence(address, false)
+	if terr != nil {
+		return 0, terr
+	}
+	if !usePending {
+		return committed + 1, nil
+	}
+
+	pending, terr := s.getSequence(address, true)
+	if terr != nil {
+		return 0, terr
+	}
+
+	addr := common.HexToAddress(address)
+	s.releaseDroppedTxs(addr)
+	return s.nonceTracker.ReserveNextSequence(addr, committed, pending), nil
+}
+
+// releaseDroppedTxs releases the sequences reserved by submitted txs the node no longer knows about.
+func (s *constructionAPIService) releaseDroppedTxs(addr common.Address) {
+	for _, txHash := range s.nonceTracker.PendingTxHashes(addr) {
+		rpcRes, rpcErr := s.client.Call("theta.GetTransaction", GetTransactionArgs{
+			Hash: txHash,
+		})
+
+		parse := func(jsonBytes []byte) (interface{}, error) {
+			txResult := GetTransactionResult{}
+			json.Unmarshal(jsonBytes, &txResult)
+			return txResult.Status, nil
+		}
+
+		status, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse)
+		if err != nil {
+			continue
+		}
+		if status == cmn.TxStatusAbandoned || status == cmn.TxStatusNotFound {
+			s.nonceTracker.Release(txHash)
+			s.pendingTxs.Remove(txHash)
+		}
+	}
+}
+
+// getSequence returns the sequence of the account, either committed or from the node's
+// screened view when preview is set.
+func (s *constructionAPIService) getSequence(address string, preview bool) (uint64, *types.Error) {
+	account, terr := s.getAccount(address, preview)
+	if terr != nil {
+		return 0, terr
+	}
+	return account.Sequence, nil
+}
+
+// getAccount returns the account, either committed or from the node's screened view when
+// preview is set.
+func (s *constructionAPIService) getAccount(address string, preview bool) (*ttypes.Account, *types.Error) {
+	rpcRes, rpcErr := s.client.Call("theta.GetAccount", GetAccountArgs{
+		Address: address,
+		Preview: preview,
+	})
+
+	parse := func(jsonBytes []byte) (interface{}, error) {
+		account := GetAccountResult{}.Account
+		err := json.Unmarshal(jsonBytes, &account)
+		if err != nil {
+			return nil, err
+		}
+		return account, nil
+	}
+
+	res, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse)
+	if err != nil || res.(*ttypes.Account) == nil {
+		return nil, cmn.ErrUnableToGetAccount
+	}
+	return res.(*ttypes.Account), nil
+}
+
+// multiplyFee scales a fee or gas price by the caller supplied fee multiplier.
+// getFeeMultiplier returns the fee multiplier of the preprocess options, 1 if none is set.
+// An explicit gas price, or the fee of a TxFee operation, is used as is, so it cannot be
+// scaled as well.
+func getFeeMultiplier(options map[string]interface{}) (float64, *types.Error) {
+	multiplier, ok := options["fee_multiplier"]
+	if !ok {
+		return 1, nil
+	}
+	feeMultiplier, ok := multiplier.(float64)
+	if !ok || feeMultiplier <= 0 {
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+		terr.Message += "invalid fee multiplier"
+		return 0, terr
+	}
+	if _, ok := options["gas_price"]; ok {
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+		terr.Message += "fee multiplier cannot be combined with a gas price"
+		return 0, terr
+	}
+	if v, ok := options["fee"]; ok {
+		if fee, ok := cmn.ToBigInt(v); !ok || fee.Sign() != 0 {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += "fee multiplier cannot be combined with a fee"
+			return 0, terr
+		}
+	}
+	return feeMultiplier, nil
+}
+
+func multiplyFee(fee *big.Int, multiplier float64) *big.Int {
+	if multiplier == 1 {
+		return fee
+	}
+	res, _ := new(big.Float).Mul(new(big.Float).SetInt(fee), big.NewFloat(multiplier)).Int(nil)
+	return res
+}
+
+// estimateGas dry-runs the smart contract call described by the preprocess options against the
+// node, and returns the gas used plus the configured safety margin, capped by the max gas limit.
+func (s *constructionAPIService) estimateGas(options map[string]interface{}, sequence uint64, gasPrice *big.Int, height uint64) (uint64, *types.Error) {
+	signer, _ := options["signer"].(string)
+	to, _ := options["to"].(string)
+
+	value := big.NewInt(0)
+	if val, ok := options["value"]; ok {
+		if value, ok = cmn.ToBigInt(val); !ok {
+			terr := cmn.CopyError(cmn.ErrUnableToEstimateGas)
+			terr.Message += "invalid value"
+			return 0, terr
+		}
+	}
+
+	var dataStr string
+	if datum, ok := options["data"]; ok {
+		dataStr, _ = datum.(string)
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(dataStr, "0x"))
+	if err != nil {
+		terr := cmn.CopyError(cmn.ErrUnableToEstimateGas)
+		terr.Message += "failed to parse data"
+		return 0, terr
+	}
+
+	maxGasLimit := ttypes.GetMaxGasLimit(height).Uint64()
+
+	tx := &ttypes.SmartContractTx{
+		From: ttypes.TxInput{
+			Address:  common.HexToAddress(signer),
+			Coins:    ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: value},
+			Sequence: sequence,
+		},
+		To: ttypes.TxOutput{
+			Coins: ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(0)},
+		},
+		GasLimit: maxGasLimit,
+		GasPrice: gasPrice,
+		Data:     data,
+	}
+	if to != "" {
+		tx.To.Address = common.HexToAddress(to)
+		tx.To.Coins.TFuelWei = value
+	}
+
+	raw, err := ttypes.TxToBytes(tx)
+	if err != nil {
+		terr := cmn.CopyError(cmn.ErrUnableToEstimateGas)
+		terr.Message += err.Error()
+		return 0, terr
+	}
+
+	rpcRes, rpcErr := s.client.Call("theta.CallSmartContract", CallSmartContractArgs{
+		SctxBytes: hex.EncodeToString(raw),
+	})
+
+	parse := func(jsonBytes []byte) (interface{}, error) {
+		callResult := CallSmartContractResult{}
+		err := json.Unmarshal(jsonBytes, &callResult)
+		if err != nil {
+			return nil, err
+		}
+		if callResult.VmError != "" {
+			return nil, fmt.Errorf("%s", callResult.VmError)
+		}
+		return uint64(callResult.GasUsed), nil
+	}
+
+	res, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse)
+	if err != nil {
+		terr := cmn.CopyError(cmn.ErrUnableToEstimateGas)
+		terr.Message += err.Error()
+		return 0, terr
+	}
+
+	gasUsed := res.(uint64)
+	margin := viper.GetUint64(cmn.CfgRosettaGasEstimationMargin)
+	gasLimit := gasUsed + gasUsed*margin/100
+	if gasLimit > maxGasLimit {
+		gasLimit = maxGasLimit
+	}
+	return gasLimit, nil
+}
+
 // decompressPubkey parses a public key in the 33-byte compressed format.
 func decompressPubkey(pubkey []byte) (*ecdsa.PublicKey, error) {
 	x, y := secp256k1.DecompressPubkey(pubkey)
@@ -640,6 +1406,39 @@ func decompressPubkey(pubkey []byte) (*ecdsa.PublicKey, error) {
 	return &ecdsa.PublicKey{X: x, Y: y, Curve: s256()}, nil
 }
 
+// Public key formats accepted by parsePubkey
+const (
+	PubkeyFormatCompressed   = "compressed"
+	PubkeyFormatUncompressed = "uncompressed"
+	PubkeyFormatRaw          = "raw"
+)
+
+// parsePubkey parses a compressed (33 bytes), uncompressed (65 bytes) or raw X||Y (64 bytes)
+// secp256k1 public key and returns the format detected.
+func parsePubkey(pubkey []byte) (*ecdsa.PublicKey, string, error) {
+	var x, y *big.Int
+	var format string
+	switch {
+	case len(pubkey) == 33 && (pubkey[0] == 0x02 || pubkey[0] == 0x03):
+		key, err := decompressPubkey(pubkey)
+		if err != nil {
+			return nil, "", err
+		}
+		x, y, format = key.X, key.Y, PubkeyFormatCompressed
+	case len(pubkey) == 65 && pubkey[0] == 0x04:
+		x, y, format = new(big.Int).SetBytes(pubkey[1:33]), new(big.Int).SetBytes(pubkey[33:]), PubkeyFormatUncompressed
+	case len(pubkey) == 64:
+		x, y, format = new(big.Int).SetBytes(pubkey[:32]), new(big.Int).SetBytes(pubkey[32:]), PubkeyFormatRaw
+	default:
+		return nil, "", fmt.Errorf("unrecognized public key of %d bytes", len(pubkey))
+	}
+
+	if !s256().IsOnCurve(x, y) {
+		return nil, "", fmt.Errorf("public key is not on the secp256k1 curve")
+	}
+	return &ecdsa.PublicKey{X: x, Y: y, Curve: s256()}, format, nil
+}
+
 // s256 returns an instance of the secp256k1 curve.
 func s256() elliptic.Curve {
 	return secp256k1.S256()
@@ -666,9 +1465,20 @@ func keccak256(data ...[]byte) []byte {
 	return d.Sum(nil)
 }
 
+// checkChainToken checks that the token is configured for the chain served by the service, the
+// tokens of a subchain being listed under its own config.
+func (s *constructionAPIService) checkChainToken(token *cmn.Token) *types.Error {
+	if !cmn.IsChainToken(s.chainID, token) {
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+		terr.Message += fmt.Sprintf("token %v is not served on %v", token.Symbol, s.chainID)
+		return terr
+	}
+	return nil
+}
+
 func getOperationDescriptions(operations []*types.Operation) (matches []*parser.Match, err *types.Error) {
 	var e error
-	if len(operations) == 2 { // SmartContractTx
+	if len(operations) == 1 { // SmartContractTx deploying a new contract
 		descriptions := &parser.Descriptions{
 			OperationDescriptions: []*parser.OperationDescription{
 				{
@@ -682,82 +1492,44 @@ func getOperationDescriptions(operations []*types.Operation) (matches []*parser.
 						Currency: cmn.GetTFuelCurrency(),
 					},
 				},
-				{
-					Type: cmn.SmartContractTxTo.String(),
-					Account: &parser.AccountDescription{
-						Exists: true,
-					},
-					Amount: &parser.AmountDescription{
-						Exists:   true,
-						Sign:     parser.PositiveOrZeroAmountSign,
-						Currency: cmn.GetTFuelCurrency(),
-					},
-				},
 			},
 			ErrUnmatched: true,
 		}
 
 		matches, e = parser.MatchOperations(descriptions, operations)
 		if e != nil {
-			err = cmn.ErrServiceInternal
+			err = cmn.CopyError(cmn.ErrServiceInternal)
 			err.Message += e.Error()
 		}
-	} else if len(operations) == 5 { // SendTx
+	} else if len(operations) == 2 { // SmartContractTx
+		// a TNT-20 token transfer moves the token currency instead of TFuel
+		currency := cmn.GetTFuelCurrency()
+		if token := cmn.GetTokenTransfer(operations); token != nil {
+			currency = operations[0].Amount.Currency
+		}
+
 		descriptions := &parser.Descriptions{
 			OperationDescriptions: []*parser.OperationDescription{
 				{
-					Type: cmn.SendTxInput.String(),
-					Account: &parser.AccountDescription{
-						Exists: true,
-					},
-					Amount: &parser.AmountDescription{
-						Exists:   true,
-						Sign:     parser.NegativeOrZeroAmountSign,
-						Currency: cmn.GetThetaCurrency(),
-					},
-				},
-				{
-					Type: cmn.SendTxInput.String(),
+					Type: cmn.SmartContractTxFrom.String(),
 					Account: &parser.AccountDescription{
 						Exists: true,
 					},
 					Amount: &parser.AmountDescription{
 						Exists:   true,
 						Sign:     parser.NegativeOrZeroAmountSign,
-						Currency: cmn.GetTFuelCurrency(),
-					},
-				},
-				{
-					Type: cmn.SendTxOutput.String(),
-					Account: &parser.AccountDescription{
-						Exists: true,
-					},
-					Amount: &parser.AmountDescription{
-						Exists:   true,
-						Sign:     parser.PositiveOrZeroAmountSign,
-						Currency: cmn.GetThetaCurrency(),
+						Currency: currency,
 					},
 				},
 				{
-					Type: cmn.SendTxOutput.String(),
+					Type: cmn.SmartContractTxTo.String(),
 					Account: &parser.AccountDescription{
 						Exists: true,
 					},
 					Amount: &parser.AmountDescription{
 						Exists:   true,
 						Sign:     parser.PositiveOrZeroAmountSign,
-						Currency: cmn.GetTFuelCurrency(),
-					},
-				},
-				{
-					Type: cmn.TxFee.String(),
-					Account: &parser.AccountDescription{
-						Exists: true,
-					},
-					Amount: &parser.AmountDescription{
-						Exists:   true,
-						Sign:     parser.NegativeOrZeroAmountSign,
-						Currency: cmn.GetTFuelCurrency(),
+						Currency: currency,
 					},
 				},
 			},
@@ -766,13 +1538,131 @@ func getOperationDescriptions(operations []*types.Operation) (matches []*parser.
 
 		matches, e = parser.MatchOperations(descriptions, operations)
 		if e != nil {
-			err = cmn.ErrServiceInternal
+			err = cmn.CopyError(cmn.ErrServiceInternal)
 			err.Message += e.Error()
 		}
 	} else {
-		err = cmn.ErrServiceInternal
+		err = cmn.CopyError(cmn.ErrServiceInternal)
 		err.Message += "invalid number of operations"
 	}
 
 	return
 }
+
+// fundOperationDescriptions describe the operations of the reserve fund, service payment and
+// split rule txs.
+var fundOperationDescriptions = map[cmn.TxType]*parser.Descriptions{
+	cmn.ReserveFundTx: {
+		OperationDescriptions: []*parser.OperationDescription{
+			{
+				Type:         cmn.ReserveFundTxSource.String(),
+				Account:      &parser.AccountDescription{Exists: true},
+				Amount:       &parser.AmountDescription{Exists: true, Sign: parser.NegativeOrZeroAmountSign},
+				AllowRepeats: true,
+			},
+			{
+				Type:    cmn.TxFee.String(),
+				Account: &parser.AccountDescription{Exists: true},
+				Amount:  &parser.AmountDescription{Exists: true, Sign: parser.NegativeOrZeroAmountSign, Currency: cmn.GetTFuelCurrency()},
+			},
+		},
+		EqualAddresses: [][]int{{0, 1}},
+		ErrUnmatched:   true,
+	},
+	cmn.ReleaseFundTx: {
+		OperationDescriptions: []*parser.OperationDescription{
+			{
+				Type:         cmn.ReleaseFundTxSource.String(),
+				Account:      &parser.AccountDescription{Exists: true},
+				Amount:       &parser.AmountDescription{Exists: true, Sign: parser.PositiveOrZeroAmountSign},
+				AllowRepeats: true,
+			},
+			{
+				Type:    cmn.TxFee.String(),
+				Account: &parser.AccountDescription{Exists: true},
+				Amount:  &parser.AmountDescription{Exists: true, Sign: parser.NegativeOrZeroAmountSign, Currency: cmn.GetTFuelCurrency()},
+			},
+		},
+		EqualAddresses: [][]int{{0, 1}},
+		ErrUnmatched:   true,
+	},
+	cmn.ServicePaymentTx: {
+		OperationDescriptions: []*parser.OperationDescription{
+			{
+				Type:    cmn.ServicePaymentTxSource.String(),
+				Account: &parser.AccountDescription{Exists: true},
+				Amount:  &parser.AmountDescription{Exists: true, Sign: parser.NegativeOrZeroAmountSign, Currency: cmn.GetTFuelCurrency()},
+			},
+			{
+				Type:    cmn.ServicePaymentTxTarget.String(),
+				Account: &parser.AccountDescription{Exists: true},
+				Amount:  &parser.AmountDescription{Exists: true, Sign: parser.PositiveOrZeroAmountSign, Currency: cmn.GetTFuelCurrency()},
+			},
+			{
+				Type:    cmn.TxFee.String(),
+				Account: &parser.AccountDescription{Exists: true},
+				Amount:  &parser.AmountDescription{Exists: true, Sign: parser.NegativeOrZeroAmountSign, Currency: cmn.GetTFuelCurrency()},
+			},
+		},
+		// the target pays the fee
+		EqualAddresses: [][]int{{1, 2}},
+		ErrUnmatched:   true,
+	},
+	cmn.SplitRuleTx: {
+		OperationDescriptions: []*parser.OperationDescription{
+			{
+				Type:    cmn.SplitRuleTxInitiator.String(),
+				Account: &parser.AccountDescription{Exists: true},
+				Amount:  &parser.AmountDescription{Exists: true, Sign: parser.NegativeOrZeroAmountSign, Currency: cmn.GetTFuelCurrency()},
+			},
+			{
+				Type:    cmn.TxFee.String(),
+				Account: &parser.AccountDescription{Exists: true},
+				Amount:  &parser.AmountDescription{Exists: true, Sign: parser.NegativeOrZeroAmountSign, Currency: cmn.GetTFuelCurrency()},
+			},
+		},
+		EqualAddresses: [][]int{{0, 1}},
+		ErrUnmatched:   true,
+	},
+}
+
+// validateFundOperations matches the operations of a reserve fund, service payment or split
+// rule tx against their descriptions.
+func validateFundOperations(txType cmn.TxType, operations []*types.Operation) *types.Error {
+	descriptions, ok := fundOperationDescriptions[txType]
+	if !ok {
+		return nil
+	}
+	if _, err := parser.MatchOperations(descriptions, operations); err != nil {
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+		terr.Message += err.Error()
+		return terr
+	}
+	return nil
+}
+
+// getTxFee returns the fee paid by the tx, zero for the tx types that carry none.
+func getTxFee(tx ttypes.Tx) ttypes.Coins {
+	var fee ttypes.Coins
+	switch tran := tx.(type) {
+	case *ttypes.SendTx:
+		fee = tran.Fee
+	case *ttypes.ReserveFundTx:
+		fee = tran.Fee
+	case *ttypes.ReleaseFundTx:
+		fee = tran.Fee
+	case *ttypes.ServicePaymentTx:
+		fee = tran.Fee
+	case *ttypes.SplitRuleTx:
+		fee = tran.Fee
+	case *ttypes.DepositStakeTx:
+		fee = tran.Fee
+	case *ttypes.DepositStakeTxV2:
+		fee = tran.Fee
+	case *ttypes.WithdrawStakeTx:
+		fee = tran.Fee
+	case *ttypes.StakeRewardDistributionTx:
+		fee = tran.Fee
+	}
+	return fee.NoNil()
+}
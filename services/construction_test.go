@@ -0,0 +1,633 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/spf13/viper"
+
+	cmn "github.com/thetatoken/theta-rosetta-rpc-adaptor/common"
+
+	"github.com/thetatoken/theta/common"
+	"github.com/thetatoken/theta/crypto"
+	"github.com/thetatoken/theta/crypto/secp256k1"
+	ttypes "github.com/thetatoken/theta/ledger/types"
+
+	jrpc "github.com/ybbus/jsonrpc"
+)
+
+const testChainID = "privatenet"
+
+func newTestConstructionService() *constructionAPIService {
+	cmn.SetChainIds([]string{testChainID})
+	return &constructionAPIService{chainID: testChainID}
+}
+
+// fakeRPCClient answers the node RPC calls of a test with respond, or fails them all with err.
+type fakeRPCClient struct {
+	jrpc.RPCClient
+	respond func(method string, args interface{}) (interface{}, *jrpc.RPCError)
+	err     error
+}
+
+func (c *fakeRPCClient) Call(method string, params ...interface{}) (*jrpc.RPCResponse, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	var args interface{}
+	if len(params) > 0 {
+		args = params[0]
+	}
+	result, rpcErr := c.respond(method, args)
+	return &jrpc.RPCResponse{Result: result, Error: rpcErr}, nil
+}
+
+func testNetworkIdentifier() *types.NetworkIdentifier {
+	return &types.NetworkIdentifier{Blockchain: cmn.ChainName, Network: testChainID}
+}
+
+func signPayload(t *testing.T, key *crypto.PrivateKey, payload *types.SigningPayload) *types.Signature {
+	sigBytes, err := secp256k1.Sign(payload.Bytes, key.ToBytes())
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	return &types.Signature{
+		SigningPayload: payload,
+		PublicKey:      &types.PublicKey{Bytes: key.PublicKey().ToBytes(), CurveType: CurveType},
+		SignatureType:  types.SignatureType(SignatureType),
+		Bytes:          sigBytes,
+	}
+}
+
+// testOperation returns a TFUEL operation of the account of key.
+func testOperation(index int64, opType cmn.TxOpType, key *crypto.PrivateKey, value string) *types.Operation {
+	return &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{Index: index},
+		Type:                opType.String(),
+		Account:             &types.AccountIdentifier{Address: key.PublicKey().Address().Hex()},
+		Amount:              &types.Amount{Value: value, Currency: cmn.GetTFuelCurrency()},
+	}
+}
+
+func servicePaymentRequest(source, target *crypto.PrivateKey) *types.ConstructionPayloadsRequest {
+	return &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: testNetworkIdentifier(),
+		Operations: []*types.Operation{
+			testOperation(0, cmn.ServicePaymentTxSource, source, "-1000"),
+			testOperation(1, cmn.ServicePaymentTxTarget, target, "1000"),
+			testOperation(2, cmn.TxFee, target, "-300000000000000000"),
+		},
+		Metadata: map[string]interface{}{
+			"type":             cmn.ServicePaymentTx,
+			"sequence":         uint64(1),
+			"payment_sequence": 1,
+			"reserve_sequence": 1,
+			"resource_id":      "rid001",
+		},
+	}
+}
+
+func TestServicePaymentSignatureRoundTrip(t *testing.T) {
+	s := newTestConstructionService()
+	ctx := context.Background()
+
+	source, _, _ := crypto.GenerateKeyPair()
+	target, _, _ := crypto.GenerateKeyPair()
+
+	// the first round only asks the source to sign
+	request := servicePaymentRequest(source, target)
+	resp, terr := s.ConstructionPayloads(ctx, request)
+	if terr != nil {
+		t.Fatalf("payloads failed: %v", terr.Message)
+	}
+	if len(resp.Payloads) != 1 || resp.Payloads[0].AccountIdentifier.Address != source.PublicKey().Address().Hex() {
+		t.Fatalf("expected the source payload only, got %v", resp.Payloads)
+	}
+	sourceSig := signPayload(t, source, resp.Payloads[0])
+
+	// the second round carries the source signature and asks the target to sign
+	request.Metadata["source_signature"] = hex.EncodeToString(sourceSig.Bytes)
+	resp, terr = s.ConstructionPayloads(ctx, request)
+	if terr != nil {
+		t.Fatalf("payloads with source signature failed: %v", terr.Message)
+	}
+	if len(resp.Payloads) != 1 || resp.Payloads[0].AccountIdentifier.Address != target.PublicKey().Address().Hex() {
+		t.Fatalf("expected the target payload only, got %v", resp.Payloads)
+	}
+	targetSig := signPayload(t, target, resp.Payloads[0])
+
+	combined, terr := s.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		NetworkIdentifier:   testNetworkIdentifier(),
+		UnsignedTransaction: resp.UnsignedTransaction,
+		Signatures:          []*types.Signature{targetSig},
+	})
+	if terr != nil {
+		t.Fatalf("combine failed: %v", terr.Message)
+	}
+
+	raw, _ := hex.DecodeString(combined.SignedTransaction)
+	tx, err := ttypes.TxFromBytes(raw)
+	if err != nil {
+		t.Fatalf("failed to decode the signed tx: %v", err)
+	}
+	servicePaymentTx := tx.(*ttypes.ServicePaymentTx)
+	if !servicePaymentTx.Source.Signature.Verify(servicePaymentTx.SourceSignBytes(testChainID), source.PublicKey().Address()) {
+		t.Errorf("invalid source signature")
+	}
+	if !servicePaymentTx.Target.Signature.Verify(servicePaymentTx.TargetSignBytes(testChainID), target.PublicKey().Address()) {
+		t.Errorf("invalid target signature")
+	}
+}
+
+func TestServicePaymentTargetSignatureWithoutSource(t *testing.T) {
+	s := newTestConstructionService()
+	ctx := context.Background()
+
+	source, _, _ := crypto.GenerateKeyPair()
+	target, _, _ := crypto.GenerateKeyPair()
+
+	resp, terr := s.ConstructionPayloads(ctx, servicePaymentRequest(source, target))
+	if terr != nil {
+		t.Fatalf("payloads failed: %v", terr.Message)
+	}
+	raw, _ := hex.DecodeString(resp.UnsignedTransaction)
+	tx, err := ttypes.TxFromBytes(raw)
+	if err != nil {
+		t.Fatalf("failed to decode the unsigned tx: %v", err)
+	}
+
+	// a target signing the tx without the source signature signs the wrong bytes
+	targetAddr := target.PublicKey().Address()
+	targetSig := signPayload(t, target, &types.SigningPayload{
+		AccountIdentifier: &types.AccountIdentifier{Address: targetAddr.Hex()},
+		Bytes:             crypto.Keccak256Hash(cmn.GetTxSignBytes(tx, targetAddr, testChainID)).Bytes(),
+	})
+	sourceSig := signPayload(t, source, resp.Payloads[0])
+
+	_, terr = s.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		NetworkIdentifier:   testNetworkIdentifier(),
+		UnsignedTransaction: resp.UnsignedTransaction,
+		Signatures:          []*types.Signature{sourceSig, targetSig},
+	})
+	if terr == nil {
+		t.Fatalf("expected the target signature over the tx without source signature to be rejected")
+	}
+}
+
+func TestGetFeeMultiplier(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    map[string]interface{}
+		multiplier float64
+		valid      bool
+	}{
+		{"unset", map[string]interface{}{"fee": "1000"}, 1, true},
+		{"multiplier", map[string]interface{}{"fee_multiplier": 1.5}, 1.5, true},
+		{"multiplier with zero fee", map[string]interface{}{"fee_multiplier": 1.5, "fee": "0"}, 1.5, true},
+		{"zero multiplier", map[string]interface{}{"fee_multiplier": float64(0)}, 0, false},
+		{"multiplier not a number", map[string]interface{}{"fee_multiplier": "2"}, 0, false},
+		{"multiplier with gas price", map[string]interface{}{"fee_multiplier": 2.0, "gas_price": "4000000000000"}, 0, false},
+		{"multiplier with fee", map[string]interface{}{"fee_multiplier": 2.0, "fee": "300000000000000000"}, 0, false},
+		{"multiplier with invalid fee", map[string]interface{}{"fee_multiplier": 2.0, "fee": "abc"}, 0, false},
+	}
+	for _, test := range tests {
+		multiplier, terr := getFeeMultiplier(test.options)
+		if (terr == nil) != test.valid {
+			t.Errorf("%v: expected valid %v, got %v", test.name, test.valid, terr)
+			continue
+		}
+		if terr == nil && multiplier != test.multiplier {
+			t.Errorf("%v: expected multiplier %v, got %v", test.name, test.multiplier, multiplier)
+		}
+	}
+}
+
+func TestPreprocessFeeMultiplierWithFee(t *testing.T) {
+	s := newTestConstructionService()
+
+	from, _, _ := crypto.GenerateKeyPair()
+	to, _, _ := crypto.GenerateKeyPair()
+	request := &types.ConstructionPreprocessRequest{
+		NetworkIdentifier: testNetworkIdentifier(),
+		Operations: []*types.Operation{
+			testOperation(0, cmn.SendTxInput, from, "-1000"),
+			testOperation(1, cmn.SendTxOutput, to, "1000"),
+			testOperation(2, cmn.TxFee, from, "-300000000000000000"),
+		},
+	}
+	multiplier := 2.0
+	request.SuggestedFeeMultiplier = &multiplier
+
+	// the SendTx fee comes from its TxFee operation, which a multiplier would silently ignore
+	if _, terr := s.ConstructionPreprocess(context.Background(), request); terr == nil {
+		t.Errorf("expected a fee multiplier with a TxFee operation to be rejected")
+	}
+}
+
+func TestPreprocessServicePaymentSequencedSigners(t *testing.T) {
+	s := newTestConstructionService()
+
+	source, _, _ := crypto.GenerateKeyPair()
+	target, _, _ := crypto.GenerateKeyPair()
+	payloads := servicePaymentRequest(source, target)
+
+	resp, terr := s.ConstructionPreprocess(context.Background(), &types.ConstructionPreprocessRequest{
+		NetworkIdentifier: testNetworkIdentifier(),
+		Operations:        payloads.Operations,
+		Metadata:          payloads.Metadata,
+	})
+	if terr != nil {
+		t.Fatalf("preprocess failed: %v", terr.Message)
+	}
+
+	// both sign, only the target sequence is consumed and reserved by /construction/metadata
+	targetAddr := target.PublicKey().Address().Hex()
+	if resp.Options["signer"] != targetAddr {
+		t.Errorf("expected the target to be the signer, got %v", resp.Options["signer"])
+	}
+	if signers, _ := resp.Options["signers"].([]string); len(signers) != 2 {
+		t.Errorf("expected the source and target signers, got %v", resp.Options["signers"])
+	}
+	if sequenced, _ := resp.Options["sequenced_signers"].([]string); len(sequenced) != 1 || sequenced[0] != targetAddr {
+		t.Errorf("expected the target only to be sequenced, got %v", resp.Options["sequenced_signers"])
+	}
+}
+
+func TestEstimateGas(t *testing.T) {
+	const height = 1000
+	maxGasLimit := ttypes.GetMaxGasLimit(height).Uint64()
+	viper.Set(cmn.CfgRosettaGasEstimationMargin, 20)
+	defer viper.Set(cmn.CfgRosettaGasEstimationMargin, nil)
+
+	signer, _, _ := crypto.GenerateKeyPair()
+	options := map[string]interface{}{
+		"signer": signer.PublicKey().Address().Hex(),
+		"to":     "0x4f8a4bd7a4b1f0cd9c8ab2ec5e0f8cd1e4ef6a77",
+		"value":  "100",
+		"data":   "0x12345678",
+	}
+
+	tests := []struct {
+		name     string
+		options  map[string]interface{}
+		result   *CallSmartContractResult
+		rpcErr   *jrpc.RPCError
+		gasLimit uint64
+		valid    bool
+	}{
+		{"margin added", options, &CallSmartContractResult{GasUsed: 21000}, nil, 25200, true},
+		{"capped at the max gas limit", options, &CallSmartContractResult{GasUsed: common.JSONUint64(maxGasLimit)}, nil, maxGasLimit, true},
+		{"vm error", options, &CallSmartContractResult{GasUsed: 21000, VmError: "execution reverted"}, nil, 0, false},
+		{"node error", options, nil, &jrpc.RPCError{Code: -32000, Message: "failed"}, 0, false},
+		{"invalid data", map[string]interface{}{"signer": options["signer"], "data": "0xzz"}, &CallSmartContractResult{GasUsed: 21000}, nil, 0, false},
+	}
+	for _, test := range tests {
+		s := newTestConstructionService()
+		s.client = &fakeRPCClient{respond: func(method string, args interface{}) (interface{}, *jrpc.RPCError) {
+			return test.result, test.rpcErr
+		}}
+
+		gasLimit, terr := s.estimateGas(test.options, 1, big.NewInt(4000000000000), height)
+		if (terr == nil) != test.valid {
+			t.Errorf("%v: expected valid %v, got %v", test.name, test.valid, terr)
+			continue
+		}
+		if terr != nil {
+			if terr.Code != cmn.ErrUnableToEstimateGas.Code {
+				t.Errorf("%v: expected ErrUnableToEstimateGas, got %v", test.name, terr.Message)
+			}
+			continue
+		}
+		if gasLimit != test.gasLimit {
+			t.Errorf("%v: expected gas limit %v, got %v", test.name, test.gasLimit, gasLimit)
+		}
+	}
+}
+
+func TestMultiInputSendTxSignatures(t *testing.T) {
+	s := newTestConstructionService()
+	ctx := context.Background()
+
+	input1, _, _ := crypto.GenerateKeyPair()
+	input2, _, _ := crypto.GenerateKeyPair()
+	output, _, _ := crypto.GenerateKeyPair()
+	other, _, _ := crypto.GenerateKeyPair()
+
+	resp, terr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: testNetworkIdentifier(),
+		Operations: []*types.Operation{
+			testOperation(0, cmn.SendTxInput, input1, "-100"),
+			testOperation(1, cmn.SendTxInput, input2, "-200"),
+			testOperation(2, cmn.SendTxOutput, output, "300"),
+			testOperation(3, cmn.TxFee, input1, "-300000000000000000"),
+		},
+		Metadata: map[string]interface{}{
+			"type":     cmn.SendTx,
+			"sequence": uint64(1),
+			"sequences": map[string]uint64{
+				input1.PublicKey().Address().Hex(): 1,
+				input2.PublicKey().Address().Hex(): 5,
+			},
+		},
+	})
+	if terr != nil {
+		t.Fatalf("payloads failed: %v", terr.Message)
+	}
+	if len(resp.Payloads) != 2 {
+		t.Fatalf("expected one payload per input, got %v", resp.Payloads)
+	}
+	sig1 := signPayload(t, input1, resp.Payloads[0])
+	sig2 := signPayload(t, input2, resp.Payloads[1])
+
+	// a signature of another key under the payload of the second input
+	forged := signPayload(t, other, resp.Payloads[1])
+
+	tests := []struct {
+		name       string
+		signatures []*types.Signature
+		valid      bool
+	}{
+		{"all signatures", []*types.Signature{sig1, sig2}, true},
+		{"signatures out of order", []*types.Signature{sig2, sig1}, true},
+		{"missing signature", []*types.Signature{sig1}, false},
+		{"duplicate signature", []*types.Signature{sig1, sig1}, false},
+		{"signature of another key", []*types.Signature{sig1, forged}, false},
+	}
+	for _, test := range tests {
+		combined, terr := s.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+			NetworkIdentifier:   testNetworkIdentifier(),
+			UnsignedTransaction: resp.UnsignedTransaction,
+			Signatures:          test.signatures,
+		})
+		if (terr == nil) != test.valid {
+			t.Errorf("%v: expected valid %v, got %v", test.name, test.valid, terr)
+			continue
+		}
+		if terr != nil {
+			continue
+		}
+
+		parsed, terr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+			NetworkIdentifier: testNetworkIdentifier(),
+			Signed:            true,
+			Transaction:       combined.SignedTransaction,
+		})
+		if terr != nil {
+			t.Errorf("%v: parse failed: %v", test.name, terr.Message)
+			continue
+		}
+		if len(parsed.AccountIdentifierSigners) != 2 ||
+			parsed.AccountIdentifierSigners[0].Address != input1.PublicKey().Address().Hex() ||
+			parsed.AccountIdentifierSigners[1].Address != input2.PublicKey().Address().Hex() {
+			t.Errorf("%v: expected both inputs as signers, got %v", test.name, parsed.AccountIdentifierSigners)
+		}
+	}
+}
+
+func TestMapSubmitError(t *testing.T) {
+	tests := []struct {
+		reason string
+		err    *types.Error
+	}{
+		{"tx already seen", cmn.ErrDuplicateTx},
+		{"ValidateInputAdvanced: Got 3, expected 5", cmn.ErrInvalidTxSequence},
+		{"invalid sequence", cmn.ErrInvalidTxSequence},
+		{"signature verification failed", cmn.ErrInvalidSignature},
+		{"Insufficient fund: balance is 0", cmn.ErrInsufficientBalance},
+		{"insufficient fee", cmn.ErrInsufficientFee},
+		{"gas price too low", cmn.ErrInsufficientFee},
+		{"mempool is full", cmn.ErrUnableToSubmitTx},
+	}
+	for _, test := range tests {
+		terr := mapSubmitError(errors.New(test.reason))
+		if terr.Code != test.err.Code {
+			t.Errorf("%v: expected %v, got %v", test.reason, test.err.Message, terr.Message)
+		}
+		if terr.Details["reason"] != test.reason {
+			t.Errorf("%v: the node reason is not kept, got %v", test.reason, terr.Details)
+		}
+	}
+}
+
+func TestWaitForTx(t *testing.T) {
+	txHash := "0x3fa1c1c5d80f1a4cfca3b0bc4d0a0cd0a0fbc5f9b0e1d3e1dbe1c1b2ec5f6c7d"
+	blockHash := common.HexToHash("0x9c3d0a1b2e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b")
+
+	tests := []struct {
+		name    string
+		waitFor string
+		result  *txStatusResult
+		status  string
+		block   bool
+		err     *types.Error
+	}{
+		{"accepted", WaitForAccepted, &txStatusResult{Status: cmn.TxStatusPending}, string(cmn.TxStatusPending), false, nil},
+		{"finalized", WaitForFinalized, &txStatusResult{Status: cmn.TxStatusFinalized, BlockHash: blockHash, BlockHeight: 100}, string(cmn.TxStatusFinalized), true, nil},
+		{"still pending at the deadline", WaitForFinalized, &txStatusResult{Status: cmn.TxStatusPending}, string(cmn.TxStatusPending), false, nil},
+		{"unknown at the deadline", WaitForFinalized, &txStatusResult{}, SubmitStatusSubmitted, false, nil},
+		{"abandoned", WaitForFinalized, &txStatusResult{Status: cmn.TxStatusAbandoned}, "", false, cmn.ErrTxAbandoned},
+	}
+	for _, test := range tests {
+		s := newTestConstructionService()
+		s.nonceTracker = cmn.NewNonceTracker(time.Minute, time.Minute)
+		s.pendingTxs = cmn.NewPendingTxCache(time.Minute)
+		s.client = &fakeRPCClient{respond: func(method string, args interface{}) (interface{}, *jrpc.RPCError) {
+			return test.result, nil
+		}}
+
+		// no timeout, the first poll decides
+		meta, terr := s.waitForTx(txHash, test.waitFor, 0)
+		if test.err != nil {
+			if terr == nil || terr.Code != test.err.Code {
+				t.Errorf("%v: expected %v, got %v", test.name, test.err.Message, terr)
+			}
+			continue
+		}
+		if terr != nil {
+			t.Errorf("%v: unexpected error %v", test.name, terr.Message)
+			continue
+		}
+		if meta["status"] != test.status {
+			t.Errorf("%v: expected status %v, got %v", test.name, test.status, meta["status"])
+		}
+		if _, ok := meta["block_identifier"]; ok != test.block {
+			t.Errorf("%v: expected block identifier %v, got %v", test.name, test.block, meta["block_identifier"])
+		}
+	}
+}
+
+func TestSubmitInvalidWaitFor(t *testing.T) {
+	viper.Set(cmn.CfgRosettaMode, cmn.CfgRosettaModeOnline)
+	defer viper.Set(cmn.CfgRosettaMode, nil)
+
+	s := newTestConstructionService()
+	ctx := context.WithValue(context.Background(), requestMetadataKey{}, map[string]interface{}{"wait_for": "mined"})
+	_, terr := s.ConstructionSubmit(ctx, &types.ConstructionSubmitRequest{NetworkIdentifier: testNetworkIdentifier()})
+	if terr == nil || terr.Code != cmn.ErrInvalidInputParam.Code {
+		t.Errorf("expected an unknown wait_for to be rejected, got %v", terr)
+	}
+}
+
+func TestValidateTx(t *testing.T) {
+	s := newTestConstructionService()
+	key, _, _ := crypto.GenerateKeyPair()
+	other, _, _ := crypto.GenerateKeyPair()
+	from := key.PublicKey().Address()
+
+	const height = 100
+	minFee := ttypes.GetSendTxMinimumTransactionFeeTFuelWei(2, height)
+	account := &ttypes.Account{
+		Address:  from,
+		Sequence: 4,
+		Balance:  ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: new(big.Int).Add(minFee, big.NewInt(1000))},
+	}
+	s.client = &fakeRPCClient{respond: func(method string, args interface{}) (interface{}, *jrpc.RPCError) {
+		switch method {
+		case "theta.GetStatus":
+			return cmn.GetStatusResult{CurrentHeight: height}, nil
+		case "theta.GetAccount":
+			return account, nil
+		}
+		return nil, &jrpc.RPCError{Code: -32601, Message: "unexpected method " + method}
+	}}
+
+	signedTx := func(signer *crypto.PrivateKey, sequence uint64, fee, amount *big.Int) string {
+		tx := &ttypes.SendTx{
+			Fee: ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: fee},
+			Inputs: []ttypes.TxInput{{
+				Address:  from,
+				Coins:    ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: new(big.Int).Add(fee, amount)},
+				Sequence: sequence,
+			}},
+			Outputs: []ttypes.TxOutput{{
+				Address: other.PublicKey().Address(),
+				Coins:   ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: amount},
+			}},
+		}
+		sig, err := signer.Sign(tx.SignBytes(testChainID))
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		tx.Inputs[0].Signature = sig
+		rawTx, err := ttypes.TxToBytes(tx)
+		if err != nil {
+			t.Fatalf("failed to encode: %v", err)
+		}
+		return hex.EncodeToString(rawTx)
+	}
+
+	tests := []struct {
+		name     string
+		signedTx string
+		err      *types.Error
+	}{
+		{"valid", signedTx(key, 5, minFee, big.NewInt(1000)), nil},
+		{"not hex", "zz", cmn.ErrUnableToParseTx},
+		{"signed by another key", signedTx(other, 5, minFee, big.NewInt(1000)), cmn.ErrInvalidSignature},
+		{"fee below the minimum", signedTx(key, 5, new(big.Int).Sub(minFee, big.NewInt(1)), big.NewInt(1000)), cmn.ErrInsufficientFee},
+		{"sequence already used", signedTx(key, 4, minFee, big.NewInt(1000)), cmn.ErrInvalidTxSequence},
+		{"sequence gap", signedTx(key, 6, minFee, big.NewInt(1000)), cmn.ErrInvalidTxSequence},
+		{"insufficient balance", signedTx(key, 5, minFee, big.NewInt(1001)), cmn.ErrInsufficientBalance},
+	}
+	for _, test := range tests {
+		_, terr := s.validateTx(test.signedTx)
+		if test.err == nil {
+			if terr != nil {
+				t.Errorf("%v: unexpected error %v", test.name, terr.Message)
+			}
+			continue
+		}
+		if terr == nil || terr.Code != test.err.Code {
+			t.Errorf("%v: expected %v, got %v", test.name, test.err.Message, terr)
+		}
+	}
+}
+
+func TestConstructionDerivePubkeyFormats(t *testing.T) {
+	s := newTestConstructionService()
+	key, _, _ := crypto.GenerateKeyPair()
+	uncompressed := key.PublicKey().ToBytes()
+	compressed := append([]byte{0x02 | uncompressed[64]&1}, uncompressed[1:33]...)
+	offCurve := append([]byte{}, uncompressed...)
+	offCurve[64] ^= 1
+
+	tests := []struct {
+		name      string
+		pubkey    *types.PublicKey
+		format    string
+		errorCode int32
+	}{
+		{"compressed", &types.PublicKey{Bytes: compressed, CurveType: CurveType}, PubkeyFormatCompressed, 0},
+		{"uncompressed", &types.PublicKey{Bytes: uncompressed, CurveType: CurveType}, PubkeyFormatUncompressed, 0},
+		{"raw", &types.PublicKey{Bytes: uncompressed[1:], CurveType: CurveType}, PubkeyFormatRaw, 0},
+		{"not on the curve", &types.PublicKey{Bytes: offCurve, CurveType: CurveType}, "", cmn.ErrInvalidInputParam.Code},
+		{"invalid length", &types.PublicKey{Bytes: uncompressed[:40], CurveType: CurveType}, "", cmn.ErrInvalidInputParam.Code},
+		{"other curve", &types.PublicKey{Bytes: uncompressed, CurveType: types.Edwards25519}, "", cmn.ErrUnsupportedPublicKeyType.Code},
+	}
+	for _, test := range tests {
+		resp, terr := s.ConstructionDerive(context.Background(), &types.ConstructionDeriveRequest{
+			NetworkIdentifier: testNetworkIdentifier(),
+			PublicKey:         test.pubkey,
+		})
+		if test.errorCode != 0 {
+			if terr == nil || terr.Code != test.errorCode {
+				t.Errorf("%v: expected error code %v, got %v", test.name, test.errorCode, terr)
+			}
+			continue
+		}
+		if terr != nil {
+			t.Errorf("%v: unexpected error %v", test.name, terr.Message)
+			continue
+		}
+		if resp.AccountIdentifier.Address != key.PublicKey().Address().Hex() {
+			t.Errorf("%v: expected address %v, got %v", test.name, key.PublicKey().Address().Hex(), resp.AccountIdentifier.Address)
+		}
+		if resp.Metadata["public_key_format"] != test.format {
+			t.Errorf("%v: expected format %v, got %v", test.name, test.format, resp.Metadata["public_key_format"])
+		}
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	key, _, _ := crypto.GenerateKeyPair()
+	other, _, _ := crypto.GenerateKeyPair()
+	signer := key.PublicKey().Address()
+	signBytes := []byte("sign bytes")
+
+	sigBytes, err := secp256k1.Sign(crypto.Keccak256Hash(signBytes).Bytes(), key.ToBytes())
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	pubkey := &types.PublicKey{Bytes: key.PublicKey().ToBytes(), CurveType: CurveType}
+
+	tests := []struct {
+		name      string
+		signature *types.Signature
+		valid     bool
+	}{
+		{"65-byte signature", &types.Signature{Bytes: sigBytes}, true},
+		{"64-byte signature", &types.Signature{Bytes: sigBytes[:64], PublicKey: pubkey}, true},
+		{"64-byte signature without public key", &types.Signature{Bytes: sigBytes[:64]}, false},
+		{"64-byte signature of another key", &types.Signature{
+			Bytes:     sigBytes[:64],
+			PublicKey: &types.PublicKey{Bytes: other.PublicKey().ToBytes(), CurveType: CurveType},
+		}, false},
+		{"invalid length", &types.Signature{Bytes: sigBytes[:63], PublicKey: pubkey}, false},
+		{"corrupted signature", &types.Signature{Bytes: append(append([]byte{}, sigBytes[:32]...), make([]byte, 33)...)}, false},
+	}
+	for _, test := range tests {
+		sig, terr := parseSignature(test.signature, signBytes, signer)
+		if (terr == nil) != test.valid {
+			t.Errorf("%v: expected valid %v, got %v", test.name, test.valid, terr)
+			continue
+		}
+		if terr == nil && !sig.Verify(signBytes, signer) {
+			t.Errorf("%v: the parsed signature does not verify", test.name)
+		}
+	}
+}
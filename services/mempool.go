@@ -2,33 +2,93 @@ package services
 
 import (
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/viper"
 
 	"github.com/coinbase/rosetta-sdk-go/server"
 	"github.com/coinbase/rosetta-sdk-go/types"
 	cmn "github.com/thetatoken/theta-rosetta-rpc-adaptor/common"
+	"github.com/thetatoken/theta/common"
+	ttypes "github.com/thetatoken/theta/ledger/types"
 	jrpc "github.com/ybbus/jsonrpc"
 )
 
 type GetPendingTransactionsArgs struct {
+	IncludeTxs bool `json:"include_txs"` // asks for the raw pending txs along with their hashes
 }
 
 type GetPendingTransactionsResult struct {
 	TxHashes []string `json:"tx_hashes"`
+	Txs      []string `json:"txs"` // hex encoded raw txs in the order of TxHashes, if reported by the node
+}
+
+// rawTxs returns the raw bytes of the pending txs reported by the node, keyed by lowercase
+// tx hash. A raw tx not hashing to its reported hash is left out, so that a pending tx is
+// never reported under an ID /construction/hash would not compute.
+func (r GetPendingTransactionsResult) rawTxs() map[string][]byte {
+	rawTxs := make(map[string][]byte)
+	for i, txHash := range r.TxHashes {
+		if i >= len(r.Txs) {
+			break
+		}
+		rawTx, err := hex.DecodeString(strings.TrimPrefix(r.Txs[i], "0x"))
+		if err != nil {
+			continue
+		}
+		if hash := cmn.TxHash(rawTx); !strings.EqualFold(hash.Hex(), txHash) {
+			logger.Errorf("Tx hash mismatch, node: %v, computed: %v", txHash, hash.Hex())
+			continue
+		}
+		rawTxs[strings.ToLower(txHash)] = rawTx
+	}
+	return rawTxs
+}
+
+type GetTxStatusResult struct {
+	Status cmn.TxStatus `json:"status"`
 }
 
 type memPoolAPIService struct {
-	client jrpc.RPCClient
+	client     jrpc.RPCClient
+	pendingTxs *cmn.PendingTxCache
+
+	mu       sync.Mutex
+	cacheTTL time.Duration
+	entries  map[string]*mempoolEntry
+}
+
+// mempoolEntry is the decoded summary of a pending tx, matched against the /mempool filters.
+type mempoolEntry struct {
+	txType    cmn.TxType
+	accounts  map[string]bool
+	fee       *big.Int
+	expiresAt time.Time
+}
+
+// mempoolFilter holds the optional /mempool request metadata filters.
+type mempoolFilter struct {
+	accounts map[string]bool
+	txTypes  map[cmn.TxType]bool
+	minFee   *big.Int
+
+	// includeUndecoded returns the pending txs that cannot be decoded without filtering them
+	includeUndecoded bool
 }
 
 // NewMemPoolAPIService creates a new instance of an MemPoolAPIService.
-func NewMemPoolAPIService(client jrpc.RPCClient) server.MempoolAPIServicer {
+func NewMemPoolAPIService(client jrpc.RPCClient, pendingTxs *cmn.PendingTxCache) server.MempoolAPIServicer {
 	return &memPoolAPIService{
-		client: client,
+		client:     client,
+		pendingTxs: pendingTxs,
+		cacheTTL:   time.Duration(viper.GetInt64(cmn.CfgRosettaMempoolCacheTTLSecs)) * time.Second,
+		entries:    make(map[string]*mempoolEntry),
 	}
 }
 
@@ -45,31 +105,212 @@ func (s *memPoolAPIService) Mempool(
 		return nil, err
 	}
 
-	rpcRes, rpcErr := s.client.Call("theta.GetPendingTransactions", GetPendingTransactionsArgs{})
+	filter, terr := parseMempoolFilter(request.Metadata)
+	if terr != nil {
+		return nil, terr
+	}
+
+	// the raw txs are only needed to filter them
+	pendingTxs, err := s.getPendingTransactions(filter != nil)
+	if err != nil {
+		return nil, cmn.ErrUnableToGetMemPool
+	}
+	rawTxs := pendingTxs.rawTxs()
+
+	resp := types.MempoolResponse{}
+	resp.TransactionIdentifiers = make([]*types.TransactionIdentifier, 0)
+	undecoded := 0
+	for _, txHash := range pendingTxs.TxHashes {
+		if filter != nil {
+			entry := s.getMempoolEntry(txHash, rawTxs[strings.ToLower(txHash)])
+			if entry == nil {
+				// a tx that cannot be decoded is only returned, unfiltered, when asked for
+				undecoded++
+				if !filter.includeUndecoded {
+					continue
+				}
+			} else if !filter.match(entry) {
+				continue
+			}
+		}
+		txId := types.TransactionIdentifier{
+			Hash: txHash,
+		}
+		resp.TransactionIdentifiers = append(resp.TransactionIdentifiers, &txId)
+	}
+
+	if undecoded > 0 && !filter.includeUndecoded {
+		terr := cmn.CopyError(cmn.ErrMempoolFilterUnavailable)
+		terr.Message += fmt.Sprintf(": %d pending transaction(s) cannot be decoded, set include_undecoded to return them unfiltered", undecoded)
+		return nil, terr
+	}
+
+	return &resp, nil
+}
+
+// getPendingTransactions returns the hashes of the pending txs, along with their raw bytes
+// when includeTxs is set and the node reports them.
+func (s *memPoolAPIService) getPendingTransactions(includeTxs bool) (GetPendingTransactionsResult, error) {
+	rpcRes, rpcErr := s.client.Call("theta.GetPendingTransactions", GetPendingTransactionsArgs{IncludeTxs: includeTxs})
 
 	parse := func(jsonBytes []byte) (interface{}, error) {
 		pendingTxs := GetPendingTransactionsResult{}
 		json.Unmarshal(jsonBytes, &pendingTxs)
+		return pendingTxs, nil
+	}
+
+	res, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse)
+	if err != nil {
+		return GetPendingTransactionsResult{}, err
+	}
+	return res.(GetPendingTransactionsResult), nil
+}
+
+// parseMempoolFilter reads the "accounts", "tx_types" and "min_fee" filters of a /mempool
+// request. It returns nil when no filter is set.
+func parseMempoolFilter(meta map[string]interface{}) (*mempoolFilter, *types.Error) {
+	if len(meta) == 0 {
+		return nil, nil
+	}
+
+	var filter mempoolFilter
+	var filtered bool
+
+	if v, ok := meta["accounts"]; ok {
+		accounts, ok := v.([]interface{})
+		if !ok {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += "accounts must be a list of addresses"
+			return nil, terr
+		}
+		filter.accounts = make(map[string]bool)
+		for _, account := range accounts {
+			addr, ok := account.(string)
+			if !ok || !common.IsHexAddress(addr) {
+				terr := cmn.CopyError(cmn.ErrInvalidAccountAddress)
+				terr.Message += fmt.Sprintf(": %v", account)
+				return nil, terr
+			}
+			filter.accounts[strings.ToLower(addr)] = true
+		}
+		filtered = true
+	}
 
-		resp := types.MempoolResponse{}
-		resp.TransactionIdentifiers = make([]*types.TransactionIdentifier, 0)
-		for _, txHash := range pendingTxs.TxHashes {
-			txId := types.TransactionIdentifier{
-				Hash: txHash,
+	if v, ok := meta["tx_types"]; ok {
+		txTypes, ok := v.([]interface{})
+		if !ok {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += "tx_types must be a list of tx types"
+			return nil, terr
+		}
+		filter.txTypes = make(map[cmn.TxType]bool)
+		for _, typ := range txTypes {
+			txType, ok := cmn.ToTxType(typ)
+			if !ok {
+				terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+				terr.Message += fmt.Sprintf("invalid tx type %v", typ)
+				return nil, terr
 			}
-			resp.TransactionIdentifiers = append(resp.TransactionIdentifiers, &txId)
+			filter.txTypes[txType] = true
 		}
+		filtered = true
+	}
 
-		return resp, nil
+	if v, ok := meta["min_fee"]; ok {
+		minFee, ok := cmn.ToBigInt(v)
+		if !ok || minFee.Sign() < 0 {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += "invalid min_fee"
+			return nil, terr
+		}
+		filter.minFee = minFee
+		filtered = true
 	}
 
-	res, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse)
+	if !filtered {
+		return nil, nil
+	}
+
+	if v, ok := meta["include_undecoded"]; ok {
+		if filter.includeUndecoded, ok = v.(bool); !ok {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += "include_undecoded must be a boolean"
+			return nil, terr
+		}
+	}
+	return &filter, nil
+}
+
+// match reports whether a decoded mempool entry passes the filter.
+func (f *mempoolFilter) match(entry *mempoolEntry) bool {
+	if f.txTypes != nil && !f.txTypes[entry.txType] {
+		return false
+	}
+	if f.minFee != nil && entry.fee.Cmp(f.minFee) < 0 {
+		return false
+	}
+	if f.accounts != nil {
+		for account := range entry.accounts {
+			if f.accounts[account] {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// getMempoolEntry returns the decoded summary of a pending tx, from the cache when possible.
+// The tx is decoded from the raw tx reported by the node, or kept when it was submitted
+// through the adaptor, nil is returned when neither is known.
+func (s *memPoolAPIService) getMempoolEntry(txHash string, rawTx []byte) *mempoolEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	key := strings.ToLower(txHash)
+	if entry, ok := s.entries[key]; ok && now.Before(entry.expiresAt) {
+		return entry
+	}
+
+	for hash, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			delete(s.entries, hash)
+		}
+	}
+
+	if rawTx == nil {
+		var ok bool
+		if rawTx, ok = s.pendingTxs.Get(txHash); !ok {
+			return nil
+		}
+	}
+	tx, err := ttypes.TxFromBytes(rawTx)
 	if err != nil {
-		return nil, cmn.ErrUnableToGetMemPool
+		return nil
+	}
+	metadata, ops, err := cmn.ParseTxForConstruction(tx)
+	if err != nil {
+		return nil
 	}
 
-	ret, _ := res.(types.MempoolResponse)
-	return &ret, nil
+	entry := &mempoolEntry{
+		accounts:  make(map[string]bool),
+		fee:       getTxFee(tx).TFuelWei,
+		expiresAt: now.Add(s.cacheTTL),
+	}
+	entry.txType, _ = metadata["type"].(cmn.TxType)
+	for _, op := range ops {
+		if op.Account != nil {
+			entry.accounts[strings.ToLower(op.Account.Address)] = true
+		}
+	}
+	if smartContractTx, ok := tx.(*ttypes.SmartContractTx); ok && smartContractTx.GasPrice != nil {
+		entry.fee = new(big.Int).Mul(smartContractTx.GasPrice, new(big.Int).SetUint64(smartContractTx.GasLimit))
+	}
+
+	s.entries[key] = entry
+	return entry
 }
 
 // MempoolTransaction implements the /mempool/transaction endpoint.
@@ -77,47 +318,79 @@ func (s *memPoolAPIService) MempoolTransaction(
 	ctx context.Context,
 	request *types.MempoolTransactionRequest,
 ) (*types.MempoolTransactionResponse, *types.Error) {
+	if !strings.EqualFold(cmn.CfgRosettaModeOnline, viper.GetString(cmn.CfgRosettaMode)) {
+		return nil, cmn.ErrUnavailableOffline
+	}
+
 	if err := cmn.ValidateNetworkIdentifier(ctx, request.NetworkIdentifier); err != nil {
 		return nil, err
 	}
 
+	txHash := request.TransactionIdentifier.Hash
+
 	rpcRes, rpcErr := s.client.Call("theta.GetTransaction", GetTransactionArgs{
-		Hash: request.TransactionIdentifier.Hash,
+		Hash: txHash,
 	})
 
+	// a pending tx comes back without its body, only the status is needed here
 	parse := func(jsonBytes []byte) (interface{}, error) {
-		txResult := GetTransactionResult{}
-		json.Unmarshal(jsonBytes, &txResult)
-
-		var gasUsed uint64
-		if txResult.Receipt != nil {
-			gasUsed = txResult.Receipt.GasUsed
+		txResult := GetTxStatusResult{}
+		err := json.Unmarshal(jsonBytes, &txResult)
+		if err != nil {
+			return nil, err
 		}
+		return txResult, nil
+	}
 
-		resp := types.MempoolTransactionResponse{}
+	res, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse)
+	if err != nil {
+		terr := cmn.CopyError(cmn.ErrUnableToGetMemPoolTx)
+		terr.Message += ": " + err.Error()
+		return nil, terr
+	}
 
-		var objMap map[string]json.RawMessage
-		json.Unmarshal(jsonBytes, &objMap)
-		if objMap["transaction"] != nil {
-			var rawTx json.RawMessage
-			json.Unmarshal(objMap["transaction"], &rawTx)
-			status := string(txResult.Status)
-			if "not_found" != status {
-				tx := cmn.ParseTx(cmn.TxType(txResult.Type), rawTx, txResult.TxHash, &status, gasUsed, txResult.BalanceChanges, nil, nil, 0)
-				resp.Transaction = &tx
-			}
-		}
-		if resp.Transaction == nil {
-			return nil, fmt.Errorf("%v", cmn.ErrUnableToGetBlkTx)
+	// only txs still pending are in the mempool
+	if res.(GetTxStatusResult).Status != cmn.TxStatusPending {
+		s.pendingTxs.Remove(txHash)
+		terr := cmn.CopyError(cmn.ErrUnableToGetMemPoolTx)
+		terr.Message += ": transaction not in mempool"
+		return nil, terr
+	}
+
+	// the tx is decoded from the raw pending tx reported by the node, or kept when submitted
+	// here. A pending tx known by neither is returned without operations, flagged as
+	// content_unknown.
+	rawTx, ok := s.pendingTxs.Get(txHash)
+	if !ok {
+		if pendingTxs, err := s.getPendingTransactions(true); err == nil {
+			rawTx, ok = pendingTxs.rawTxs()[strings.ToLower(txHash)]
 		}
-		return resp, nil
+	}
+	if !ok {
+		return &types.MempoolTransactionResponse{
+			Transaction: &types.Transaction{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: txHash},
+				Operations:            []*types.Operation{},
+				Metadata:              map[string]interface{}{"content_unknown": true},
+			},
+		}, nil
 	}
 
-	res, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse)
+	tx, err := ttypes.TxFromBytes(rawTx)
+	if err != nil {
+		terr := cmn.CopyError(cmn.ErrUnableToGetMemPoolTx)
+		terr.Message += ": " + err.Error()
+		return nil, terr
+	}
+
+	transaction, err := cmn.ParsePendingTx(tx, txHash)
 	if err != nil {
-		return nil, cmn.ErrUnableToGetBlkTx
+		terr := cmn.CopyError(cmn.ErrUnableToGetMemPoolTx)
+		terr.Message += ": " + err.Error()
+		return nil, terr
 	}
 
-	ret, _ := res.(types.MempoolTransactionResponse)
-	return &ret, nil
+	return &types.MempoolTransactionResponse{
+		Transaction: transaction,
+	}, nil
 }
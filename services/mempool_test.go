@@ -0,0 +1,117 @@
+package services
+
+import (
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	cmn "github.com/thetatoken/theta-rosetta-rpc-adaptor/common"
+
+	"github.com/thetatoken/theta/common"
+	ttypes "github.com/thetatoken/theta/ledger/types"
+)
+
+func TestMempoolFilter(t *testing.T) {
+	from := common.HexToAddress("0x2e833968e5bb786ae419c4d13189fb081cc43bab")
+	to := common.HexToAddress("0x9f1233798e905e173560071255140b4a8abd3ec6")
+	other := common.HexToAddress("0x4f8a4bd7a4b1f0cd9c8ab2ec5e0f8cd1e4ef6a77")
+
+	rawTx, err := ttypes.TxToBytes(&ttypes.SendTx{
+		Fee: ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(1000)},
+		Inputs: []ttypes.TxInput{{
+			Address:  from,
+			Coins:    ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(1100)},
+			Sequence: 1,
+		}},
+		Outputs: []ttypes.TxOutput{{
+			Address: to,
+			Coins:   ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(100)},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	txHash := cmn.TxHash(rawTx).Hex()
+
+	// the tx comes from the node, it was not submitted through the adaptor
+	pending := GetPendingTransactionsResult{TxHashes: []string{txHash}, Txs: []string{hex.EncodeToString(rawTx)}}
+	s := &memPoolAPIService{
+		pendingTxs: cmn.NewPendingTxCache(time.Minute),
+		cacheTTL:   time.Minute,
+		entries:    make(map[string]*mempoolEntry),
+	}
+	entry := s.getMempoolEntry(txHash, pending.rawTxs()[strings.ToLower(txHash)])
+	if entry == nil {
+		t.Fatalf("failed to decode the pending tx reported by the node")
+	}
+
+	tests := []struct {
+		name  string
+		meta  map[string]interface{}
+		valid bool
+		match bool
+	}{
+		{"no filter", nil, true, true},
+		{"sender", map[string]interface{}{"accounts": []interface{}{from.Hex()}}, true, true},
+		{"receiver", map[string]interface{}{"accounts": []interface{}{strings.ToLower(to.Hex())}}, true, true},
+		{"other account", map[string]interface{}{"accounts": []interface{}{other.Hex()}}, true, false},
+		{"tx type name", map[string]interface{}{"tx_types": []interface{}{"SendTx"}}, true, true},
+		{"other tx type", map[string]interface{}{"tx_types": []interface{}{"SmartContractTx"}}, true, false},
+		{"min fee met", map[string]interface{}{"min_fee": "1000"}, true, true},
+		{"min fee not met", map[string]interface{}{"min_fee": "1001"}, true, false},
+		{"invalid account", map[string]interface{}{"accounts": []interface{}{"0x1234"}}, false, false},
+		{"invalid tx type", map[string]interface{}{"tx_types": []interface{}{"NoSuchTx"}}, false, false},
+		{"negative min fee", map[string]interface{}{"min_fee": "-1"}, false, false},
+		{"invalid include_undecoded", map[string]interface{}{"min_fee": "0", "include_undecoded": "yes"}, false, false},
+	}
+	for _, test := range tests {
+		filter, terr := parseMempoolFilter(test.meta)
+		if (terr == nil) != test.valid {
+			t.Errorf("%v: expected valid %v, got %v", test.name, test.valid, terr)
+			continue
+		}
+		if terr != nil {
+			continue
+		}
+		if match := filter == nil || filter.match(entry); match != test.match {
+			t.Errorf("%v: expected match %v, got %v", test.name, test.match, match)
+		}
+	}
+}
+
+func TestPendingRawTxs(t *testing.T) {
+	rawTx, err := ttypes.TxToBytes(&ttypes.WithdrawStakeTx{
+		Fee: ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(1000)},
+		Source: ttypes.TxInput{
+			Address:  common.HexToAddress("0x2e833968e5bb786ae419c4d13189fb081cc43bab"),
+			Coins:    ttypes.Coins{ThetaWei: big.NewInt(0), TFuelWei: big.NewInt(0)},
+			Sequence: 1,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	txHash := cmn.TxHash(rawTx).Hex()
+	otherHash := cmn.TxHash([]byte("other")).Hex()
+
+	tests := []struct {
+		name    string
+		pending GetPendingTransactionsResult
+		decoded bool
+	}{
+		{"raw tx", GetPendingTransactionsResult{TxHashes: []string{txHash}, Txs: []string{hex.EncodeToString(rawTx)}}, true},
+		{"0x prefixed raw tx", GetPendingTransactionsResult{TxHashes: []string{txHash}, Txs: []string{"0x" + hex.EncodeToString(rawTx)}}, true},
+		{"hashes only", GetPendingTransactionsResult{TxHashes: []string{txHash}}, false},
+		{"hash mismatch", GetPendingTransactionsResult{TxHashes: []string{otherHash}, Txs: []string{hex.EncodeToString(rawTx)}}, false},
+		{"invalid hex", GetPendingTransactionsResult{TxHashes: []string{txHash}, Txs: []string{"zz"}}, false},
+	}
+	for _, test := range tests {
+		rawTxs := test.pending.rawTxs()
+		_, ok := rawTxs[strings.ToLower(test.pending.TxHashes[0])]
+		if ok != test.decoded {
+			t.Errorf("%v: expected decoded %v, got %v", test.name, test.decoded, ok)
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/coinbase/rosetta-sdk-go/server"
 	"github.com/coinbase/rosetta-sdk-go/types"
@@ -13,17 +15,45 @@ import (
 	jrpc "github.com/ybbus/jsonrpc"
 
 	cmn "github.com/thetatoken/theta-rosetta-rpc-adaptor/common"
+	"github.com/thetatoken/theta/common"
 	"github.com/thetatoken/theta/version"
 )
 
+const (
+	SyncStageSnapshotDownload = "snapshot_download"
+	SyncStageBlockSync        = "block_sync"
+	SyncStageCaughtUp         = "caught_up"
+
+	NodeTypeValidator = "validator"
+	NodeTypeGuardian  = "guardian"
+	NodeTypeEdgeNode  = "edge_node"
+	NodeTypeFullNode  = "full_node"
+
+	// pruningProbeInterval sets how often the retained state window is probed again.
+	pruningProbeInterval = time.Hour
+)
+
+// stakeSubAccounts are the sub-accounts holding the THETA and TFuel staked for each purpose. Their
+// balances also change outside operations, when stake rewards are paid or stakes are returned.
+var stakeSubAccounts = []string{"validator_stake", "guardian_stake", "elite_edge_node_stake"}
+
 type networkAPIService struct {
-	client jrpc.RPCClient
+	client            jrpc.RPCClient
+	networkIdentifier *types.NetworkIdentifier
+
+	mu             sync.Mutex
+	retainedBlocks uint64 // 0 when the node does not prune its state
+	probedAt       time.Time
+
+	timestampStartIndex       *int64 // nil when the genesis block has a valid timestamp
+	timestampStartIndexCached bool   // set once the genesis block has been read
 }
 
 // NewAccountAPIService creates a new instance of an AccountAPIService.
-func NewNetworkAPIService(client jrpc.RPCClient) server.NetworkAPIServicer {
+func NewNetworkAPIService(client jrpc.RPCClient, networkIdentifier *types.NetworkIdentifier) server.NetworkAPIServicer {
 	return &networkAPIService{
-		client: client,
+		client:            client,
+		networkIdentifier: networkIdentifier,
 	}
 }
 
@@ -33,11 +63,7 @@ func (s *networkAPIService) NetworkList(
 	request *types.MetadataRequest,
 ) (*types.NetworkListResponse, *types.Error) {
 	return &types.NetworkListResponse{
-		NetworkIdentifiers: []*types.NetworkIdentifier{{
-			Blockchain: "theta",
-			Network:    cmn.GetChainId(),
-		},
-		},
+		NetworkIdentifiers: []*types.NetworkIdentifier{s.networkIdentifier},
 	}, nil
 }
 
@@ -75,18 +101,60 @@ func (s *networkAPIService) NetworkStatus(
 		return nil, cmn.ErrUnableToGetNodeStatus
 	}
 
+	// edge nodes are the peers missing from the list without edge nodes
+	nonEdgePeers := make(map[string]bool)
+	if !skipEdgeNode {
+		nonEdge, err := GetPeers(s.client, true)
+		if err != nil {
+			return nil, cmn.ErrUnableToGetNodeStatus
+		}
+		for _, peerId := range nonEdge.Peers {
+			nonEdgePeers[strings.ToLower(peerId)] = true
+		}
+	}
+
+	nodeTypes := s.getNodeTypes(status.LatestFinalizedBlockHeight)
+
 	peerList := make([]*types.Peer, 0)
 	for _, peerId := range peers.Peers {
-		peer := types.Peer{PeerID: peerId}
+		nodeType, ok := nodeTypes[strings.ToLower(peerId)]
+		if !ok {
+			if !skipEdgeNode && !nonEdgePeers[strings.ToLower(peerId)] {
+				nodeType = NodeTypeEdgeNode
+			} else {
+				nodeType = NodeTypeFullNode
+			}
+		}
+		peer := types.Peer{
+			PeerID: peerId,
+			Metadata: map[string]interface{}{
+				"address":   peerId, // the peer ID of a Theta node is its address
+				"node_type": nodeType,
+			},
+		}
 		peerList = append(peerList, &peer)
 	}
 
+	stage := SyncStageCaughtUp
+	if status.Syncing {
+		if status.LatestFinalizedBlockHeight <= status.SnapshotBlockHeight {
+			stage = SyncStageSnapshotDownload
+		} else {
+			stage = SyncStageBlockSync
+		}
+	}
+
+	oldestBlock, terr := s.getOldestBlock(status)
+	if terr != nil {
+		return nil, terr
+	}
+
 	resp := &types.NetworkStatusResponse{
 		CurrentBlockIdentifier: &types.BlockIdentifier{Index: lastFinalized, Hash: status.LatestFinalizedBlockHash.Hex()},
 		CurrentBlockTimestamp:  status.LatestFinalizedBlockTime.ToInt().Int64() * 1000,
 		GenesisBlockIdentifier: &types.BlockIdentifier{Index: 0, Hash: status.GenesisBlockHash.Hex()},
-		OldestBlockIdentifier:  &types.BlockIdentifier{Index: int64(status.SnapshotBlockHeight), Hash: status.SnapshotBlockHash.Hex()},
-		SyncStatus:             &types.SyncStatus{CurrentIndex: &lastFinalized, TargetIndex: &currHeight, Synced: &synced},
+		OldestBlockIdentifier:  oldestBlock,
+		SyncStatus:             &types.SyncStatus{CurrentIndex: &lastFinalized, TargetIndex: &currHeight, Stage: &stage, Synced: &synced},
 		Peers:                  peerList,
 	}
 
@@ -107,49 +175,136 @@ func (s *networkAPIService) NetworkOptions(
 			RosettaVersion: viper.GetString(cmn.CfgRosettaVersion),
 			NodeVersion:    version.Version,
 		},
-		Allow: &types.Allow{
-			OperationStatuses: []*types.OperationStatus{
-				{
-					Status:     cmn.BlockStatusPending.String(),
-					Successful: false,
-				},
-				{
-					Status:     cmn.BlockStatusValid.String(),
-					Successful: true,
-				},
-				{
-					Status:     cmn.BlockStatusInvalid.String(),
-					Successful: false,
-				},
-				{
-					Status:     cmn.BlockStatusCommitted.String(),
-					Successful: true,
-				},
-				{
-					Status:     cmn.BlockStatusDirectlyFinalized.String(),
-					Successful: true,
-				},
-				{
-					Status:     cmn.BlockStatusIndirectlyFinalized.String(),
-					Successful: true,
-				},
-				{
-					Status:     cmn.BlockStatusTrusted.String(),
-					Successful: true,
-				},
-				{
-					Status:     cmn.BlockStatusDisposed.String(),
-					Successful: true,
-				},
+		Allow: s.getAllow(),
+	}, nil
+}
+
+// getAllow lists what the adaptor serves given its config: the cross chain operations only
+// when Metachain token banks are configured, the /call methods, and the balance exemptions of
+// the staking sub-accounts and of the TNT-20 tokens configured for the chain.
+func (s *networkAPIService) getAllow() *types.Allow {
+	operationTypes := make([]string, 0)
+	tokenBanks := len(viper.GetStringSlice(cmn.CfgRosettaTokenBanks)) > 0
+	for _, opType := range cmn.TxOpTypes() {
+		if !tokenBanks && (opType == cmn.CrossChainTransferOut.String() || opType == cmn.CrossChainTransferIn.String()) {
+			continue
+		}
+		operationTypes = append(operationTypes, opType)
+	}
+
+	// THETA and TFuel balance changes of the main accounts are all reported as operations, stake
+	// returns included. Token balances also change through contract calls whose token legs are
+	// not reported, like the TNT-20 bridge transfers, which carry no amount.
+	chainID := s.networkIdentifier.Network
+	if s.networkIdentifier.SubNetworkIdentifier != nil {
+		chainID = s.networkIdentifier.SubNetworkIdentifier.Network
+	}
+	balanceExemptions := make([]*types.BalanceExemption, 0)
+	for i := range stakeSubAccounts {
+		for _, currency := range []*types.Currency{cmn.GetThetaCurrency(), cmn.GetTFuelCurrency()} {
+			balanceExemptions = append(balanceExemptions, &types.BalanceExemption{
+				SubAccountAddress: &stakeSubAccounts[i],
+				Currency:          currency,
+				ExemptionType:     types.BalanceDynamic,
+			})
+		}
+	}
+	for _, token := range cmn.GetChainTokens(chainID) {
+		token := token
+		balanceExemptions = append(balanceExemptions, &types.BalanceExemption{
+			Currency:      cmn.GetTokenCurrency(&token),
+			ExemptionType: types.BalanceDynamic,
+		})
+	}
+
+	return &types.Allow{
+		OperationStatuses: []*types.OperationStatus{
+			{
+				Status:     cmn.BlockStatusPending.String(),
+				Successful: false,
+			},
+			{
+				Status:     cmn.BlockStatusValid.String(),
+				Successful: true,
+			},
+			{
+				Status:     cmn.BlockStatusInvalid.String(),
+				Successful: false,
+			},
+			{
+				Status:     cmn.BlockStatusCommitted.String(),
+				Successful: true,
+			},
+			{
+				Status:     cmn.BlockStatusDirectlyFinalized.String(),
+				Successful: true,
+			},
+			{
+				Status:     cmn.BlockStatusIndirectlyFinalized.String(),
+				Successful: true,
+			},
+			{
+				Status:     cmn.BlockStatusTrusted.String(),
+				Successful: true,
+			},
+			{
+				Status:     cmn.BlockStatusDisposed.String(),
+				Successful: true,
 			},
-			OperationTypes:          cmn.TxOpTypes(),
-			Errors:                  cmn.ErrorList,
-			HistoricalBalanceLookup: true,
-			MempoolCoins:            true, // Any Rosetta implementation that can update an AccountIdentifier's unspent coins based on the
-			// contents of the mempool should populate this field as true. If false, requests to
-			// `/account/coins` that set `include_mempool` as true will be automatically rejected
 		},
-	}, nil
+		OperationTypes:          operationTypes,
+		Errors:                  cmn.ErrorList,
+		HistoricalBalanceLookup: true,
+		TimestampStartIndex:     s.getTimestampStartIndex(),
+		CallMethods:             CallMethods(),
+		BalanceExemptions:       balanceExemptions,
+		MempoolCoins:            true, // Any Rosetta implementation that can update an AccountIdentifier's unspent coins based on the
+		// contents of the mempool should populate this field as true. If false, requests to
+		// `/account/coins` that set `include_mempool` as true will be automatically rejected
+	}
+}
+
+// getTimestampStartIndex returns 1 when the genesis block carries no timestamp, so that its
+// timestamp is not validated, and nil when it does. It is left out in offline mode, or while
+// the genesis block cannot be read.
+func (s *networkAPIService) getTimestampStartIndex() *int64 {
+	if !strings.EqualFold(cmn.CfgRosettaModeOnline, viper.GetString(cmn.CfgRosettaMode)) {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.timestampStartIndexCached {
+		return s.timestampStartIndex
+	}
+
+	rpcRes, rpcErr := s.client.Call("theta.GetBlockByHeight", GetBlockByHeightArgs{Height: 0})
+	parse := func(jsonBytes []byte) (interface{}, error) {
+		var tblock struct {
+			Timestamp *common.JSONBig `json:"timestamp"`
+		}
+		err := json.Unmarshal(jsonBytes, &tblock)
+		if err != nil {
+			return nil, err
+		}
+		if tblock.Timestamp == nil {
+			return nil, fmt.Errorf("genesis block not found")
+		}
+		return tblock.Timestamp.ToInt().Int64(), nil
+	}
+	res, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse)
+	if err != nil {
+		logger.Warnf("Failed to get genesis block: %v", err)
+		return nil
+	}
+
+	if res.(int64) <= 0 {
+		index := int64(1)
+		s.timestampStartIndex = &index
+	}
+	s.timestampStartIndexCached = true
+	return s.timestampStartIndex
 }
 
 type GetPeersArgs struct {
@@ -174,3 +329,128 @@ func GetPeers(client jrpc.RPCClient, skipEdgeNode bool) (*GetPeersResult, error)
 
 	return &trpcResult, nil
 }
+
+// getNodeTypes returns the validators and guardians at the height, keyed by their lowercase address.
+func (s *networkAPIService) getNodeTypes(height common.JSONUint64) map[string]string {
+	nodeTypes := make(map[string]string)
+
+	rpcRes, rpcErr := s.client.Call("theta.GetGcpByHeight", cmn.GetStakeByHeightArgs{Height: height})
+	parse := func(jsonBytes []byte) (interface{}, error) {
+		gcpResult := cmn.GetGcpResult{}
+		err := json.Unmarshal(jsonBytes, &gcpResult)
+		return gcpResult, err
+	}
+	if res, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse); err == nil {
+		gcpResult := res.(cmn.GetGcpResult)
+		if len(gcpResult.BlockHashGcpPairs) > 0 && gcpResult.BlockHashGcpPairs[0].Gcp != nil {
+			for _, guardian := range gcpResult.BlockHashGcpPairs[0].Gcp.SortedGuardians {
+				nodeTypes[strings.ToLower(guardian.Holder.Hex())] = NodeTypeGuardian
+			}
+		}
+	}
+
+	rpcRes, rpcErr = s.client.Call("theta.GetVcpByHeight", cmn.GetStakeByHeightArgs{Height: height})
+	parse = func(jsonBytes []byte) (interface{}, error) {
+		vcpResult := cmn.GetVcpResult{}
+		err := json.Unmarshal(jsonBytes, &vcpResult)
+		return vcpResult, err
+	}
+	if res, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse); err == nil {
+		vcpResult := res.(cmn.GetVcpResult)
+		if len(vcpResult.BlockHashVcpPairs) > 0 && vcpResult.BlockHashVcpPairs[0].Vcp != nil {
+			for _, candidate := range vcpResult.BlockHashVcpPairs[0].Vcp.SortedCandidates {
+				nodeTypes[strings.ToLower(candidate.Holder.Hex())] = NodeTypeValidator
+			}
+		}
+	}
+
+	return nodeTypes
+}
+
+// getOldestBlock returns the oldest block whose state can still be queried by /account/balance,
+// i.e. the latest of the snapshot block and the first block retained by state pruning.
+func (s *networkAPIService) getOldestBlock(status *cmn.GetStatusResult) (*types.BlockIdentifier, *types.Error) {
+	oldest := status.SnapshotBlockHeight
+	latest := status.LatestFinalizedBlockHeight
+
+	retained := s.getRetainedBlocks(status)
+	if retained > 0 && uint64(latest) >= retained && latest-common.JSONUint64(retained)+1 > oldest {
+		oldest = latest - common.JSONUint64(retained) + 1
+	}
+
+	if oldest == status.SnapshotBlockHeight {
+		return &types.BlockIdentifier{Index: int64(oldest), Hash: status.SnapshotBlockHash.Hex()}, nil
+	}
+
+	blk, terr := cmn.GetBlockIdentifierByHeight(s.client, oldest)
+	if terr != nil {
+		return nil, terr
+	}
+	return &types.BlockIdentifier{Index: int64(oldest), Hash: blk.Hash.Hex()}, nil
+}
+
+// getRetainedBlocks returns the number of recent blocks whose state the node keeps, from the
+// config if set, otherwise probed from the node and cached for pruningProbeInterval.
+func (s *networkAPIService) getRetainedBlocks(status *cmn.GetStatusResult) uint64 {
+	if retained := viper.GetUint64(cmn.CfgRosettaStatePruningRetainedBlocks); retained > 0 {
+		return retained
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.probedAt.IsZero() && time.Since(s.probedAt) < pruningProbeInterval {
+		return s.retainedBlocks
+	}
+
+	low, high := status.SnapshotBlockHeight, status.LatestFinalizedBlockHeight
+	if high <= low || s.isStateAvailable(low) {
+		s.retainedBlocks = 0
+		s.probedAt = time.Now()
+		return 0
+	}
+
+	// binary search the first height with state, the state of low is pruned
+	for low+1 < high {
+		mid := low + (high-low)/2
+		if s.isStateAvailable(mid) {
+			high = mid
+		} else {
+			low = mid
+		}
+	}
+
+	s.retainedBlocks = uint64(status.LatestFinalizedBlockHeight-high) + 1
+	s.probedAt = time.Now()
+	return s.retainedBlocks
+}
+
+// isStateAvailable checks whether the node still has the state at the height, by querying the
+// account of the block proposer, which always exists in that state.
+func (s *networkAPIService) isStateAvailable(height common.JSONUint64) bool {
+	rpcRes, rpcErr := s.client.Call("theta.GetBlockByHeight", GetBlockByHeightArgs{Height: height})
+	parse := func(jsonBytes []byte) (interface{}, error) {
+		// only the proposer is decoded, the txs are not needed
+		var tblock struct {
+			Proposer *common.Address `json:"proposer"`
+		}
+		err := json.Unmarshal(jsonBytes, &tblock)
+		if err != nil {
+			return nil, err
+		}
+		if tblock.Proposer == nil {
+			return nil, fmt.Errorf("block %v not found", height)
+		}
+		return *tblock.Proposer, nil
+	}
+	res, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse)
+	if err != nil {
+		return false
+	}
+
+	rpcRes, rpcErr = s.client.Call("theta.GetAccount", GetAccountArgs{
+		Address: res.(common.Address).Hex(),
+		Height:  height,
+	})
+	return rpcErr == nil && rpcRes != nil && rpcRes.Error == nil
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/spf13/viper"
+	jrpc "github.com/ybbus/jsonrpc"
+
+	cmn "github.com/thetatoken/theta-rosetta-rpc-adaptor/common"
+
+	"github.com/thetatoken/theta/common"
+)
+
+func TestGetAllow(t *testing.T) {
+	mainToken := cmn.Token{Symbol: "TKN", Decimals: 18, ContractAddress: "0x4f8a4bd7a4b1f0cd9c8ab2ec5e0f8cd1e4ef6a77"}
+	subchainToken := cmn.Token{Symbol: "SUB", Decimals: 18, ContractAddress: "0x9f1233798e905e173560071255140b4a8abd3ec6"}
+
+	viper.Set(cmn.CfgRosettaTokens, []map[string]interface{}{
+		{"symbol": mainToken.Symbol, "decimals": mainToken.Decimals, "contractAddress": mainToken.ContractAddress},
+	})
+	defer viper.Set(cmn.CfgRosettaTokens, nil)
+	cmn.SetSubchainTokens("tsub360777", []cmn.Token{subchainToken})
+
+	mainchain := &types.NetworkIdentifier{Blockchain: cmn.ChainName, Network: testChainID}
+	subchain := &types.NetworkIdentifier{
+		Blockchain:           cmn.ChainName,
+		Network:              testChainID,
+		SubNetworkIdentifier: &types.SubNetworkIdentifier{Network: "tsub360777"},
+	}
+
+	tests := []struct {
+		name       string
+		network    *types.NetworkIdentifier
+		tokenBanks []string
+		token      cmn.Token
+		crossChain bool
+	}{
+		{"main chain", mainchain, nil, mainToken, false},
+		{"subchain", subchain, nil, subchainToken, false},
+		{"token banks", mainchain, []string{"0x2e833968e5bb786ae419c4d13189fb081cc43bab"}, mainToken, true},
+	}
+	for _, test := range tests {
+		viper.Set(cmn.CfgRosettaTokenBanks, test.tokenBanks)
+		s := &networkAPIService{networkIdentifier: test.network, timestampStartIndexCached: true}
+		allow := s.getAllow()
+
+		// every staking sub-account is exempted for both THETA and TFuel, and the chain token
+		// for the main accounts
+		exempted := make(map[string]bool)
+		for _, exemption := range allow.BalanceExemptions {
+			key := exemption.Currency.Symbol
+			if exemption.SubAccountAddress != nil {
+				key = *exemption.SubAccountAddress + "/" + key
+			}
+			exempted[key] = true
+		}
+		for _, subAccount := range stakeSubAccounts {
+			for _, currency := range []*types.Currency{cmn.GetThetaCurrency(), cmn.GetTFuelCurrency()} {
+				if !exempted[subAccount+"/"+currency.Symbol] {
+					t.Errorf("%v: %v %v not exempted", test.name, subAccount, currency.Symbol)
+				}
+			}
+		}
+		if len(allow.BalanceExemptions) != 2*len(stakeSubAccounts)+1 || !exempted[test.token.Symbol] {
+			t.Errorf("%v: expected the %v token only to be exempted, got %v", test.name, test.token.Symbol, exempted)
+		}
+
+		crossChain := false
+		for _, opType := range allow.OperationTypes {
+			if opType == cmn.CrossChainTransferOut.String() {
+				crossChain = true
+			}
+		}
+		if crossChain != test.crossChain {
+			t.Errorf("%v: expected cross chain operations %v, got %v", test.name, test.crossChain, crossChain)
+		}
+
+		if len(allow.CallMethods) != len(callHandlers) {
+			t.Errorf("%v: expected call methods %v, got %v", test.name, CallMethods(), allow.CallMethods)
+		}
+		for _, method := range allow.CallMethods {
+			if _, ok := callHandlers[method]; !ok {
+				t.Errorf("%v: call method %v has no handler", test.name, method)
+			}
+		}
+	}
+	viper.Set(cmn.CfgRosettaTokenBanks, nil)
+}
+
+// prunedNode answers the block and account queries of a node keeping the state from
+// firstRetained on, and every block up to latest.
+func prunedNode(firstRetained, latest common.JSONUint64) *fakeRPCClient {
+	proposer := common.HexToAddress("0x2e833968e5bb786ae419c4d13189fb081cc43bab")
+	return &fakeRPCClient{respond: func(method string, args interface{}) (interface{}, *jrpc.RPCError) {
+		switch method {
+		case "theta.GetBlockByHeight":
+			var height common.JSONUint64
+			switch args := args.(type) {
+			case GetBlockByHeightArgs:
+				height = args.Height
+			case cmn.GetBlockIdentifierByHeightArgs:
+				height = args.Height
+			}
+			if height > latest {
+				return nil, &jrpc.RPCError{Code: -32000, Message: "block not found"}
+			}
+			return map[string]interface{}{
+				"height":   height,
+				"hash":     common.BytesToHash([]byte(fmt.Sprintf("block %d", height))),
+				"proposer": proposer,
+			}, nil
+		case "theta.GetAccount":
+			if args.(GetAccountArgs).Height < firstRetained {
+				return nil, &jrpc.RPCError{Code: -32000, Message: "state pruned"}
+			}
+			return map[string]interface{}{"address": proposer}, nil
+		}
+		return nil, &jrpc.RPCError{Code: -32601, Message: "unexpected method " + method}
+	}}
+}
+
+func TestGetOldestBlock(t *testing.T) {
+	snapshotHash := common.BytesToHash([]byte("snapshot"))
+	tests := []struct {
+		name          string
+		configured    uint64
+		firstRetained common.JSONUint64
+		retained      uint64
+		oldest        int64
+	}{
+		{"no pruning", 0, 0, 0, 10},
+		{"probed pruning", 0, 801, 200, 801},
+		{"configured pruning", 100, 801, 100, 901},
+		{"retained beyond the snapshot", 5000, 0, 5000, 10},
+	}
+	for _, test := range tests {
+		viper.Set(cmn.CfgRosettaStatePruningRetainedBlocks, test.configured)
+		s := &networkAPIService{client: prunedNode(test.firstRetained, 1000)}
+		status := &cmn.GetStatusResult{SnapshotBlockHeight: 10, SnapshotBlockHash: snapshotHash, LatestFinalizedBlockHeight: 1000}
+
+		if retained := s.getRetainedBlocks(status); retained != test.retained {
+			t.Errorf("%v: expected %v retained blocks, got %v", test.name, test.retained, retained)
+		}
+		oldest, terr := s.getOldestBlock(status)
+		if terr != nil {
+			t.Errorf("%v: unexpected error %v", test.name, terr.Message)
+			continue
+		}
+		if oldest.Index != test.oldest {
+			t.Errorf("%v: expected oldest block %v, got %v", test.name, test.oldest, oldest.Index)
+		}
+		if test.oldest == 10 && oldest.Hash != snapshotHash.Hex() {
+			t.Errorf("%v: expected the snapshot block hash, got %v", test.name, oldest.Hash)
+		}
+	}
+	viper.Set(cmn.CfgRosettaStatePruningRetainedBlocks, nil)
+}
+
+func TestNetworkStatusStageAndNodeTypes(t *testing.T) {
+	viper.Set(cmn.CfgRosettaMode, cmn.CfgRosettaModeOnline)
+	defer viper.Set(cmn.CfgRosettaMode, nil)
+	cmn.SetChainIds([]string{testChainID})
+
+	validator := "0x2e833968e5bb786ae419c4d13189fb081cc43bab"
+	guardian := "0x9f1233798e905e173560071255140b4a8abd3ec6"
+	edgeNode := "0x4f8a4bd7a4b1f0cd9c8ab2ec5e0f8cd1e4ef6a77"
+	fullNode := "0x0d2fd67d573c8ecb4161510fc00754d64b401f86"
+
+	tests := []struct {
+		name         string
+		syncing      bool
+		finalized    common.JSONUint64
+		skipEdgeNode bool
+		stage        string
+		nodeTypes    map[string]string
+	}{
+		{"caught up", false, 1000, false, SyncStageCaughtUp, map[string]string{
+			validator: NodeTypeValidator, guardian: NodeTypeGuardian, edgeNode: NodeTypeEdgeNode, fullNode: NodeTypeFullNode,
+		}},
+		{"downloading the snapshot", true, 10, true, SyncStageSnapshotDownload, map[string]string{
+			validator: NodeTypeValidator, guardian: NodeTypeGuardian, fullNode: NodeTypeFullNode,
+		}},
+		{"syncing blocks", true, 500, true, SyncStageBlockSync, map[string]string{
+			validator: NodeTypeValidator, guardian: NodeTypeGuardian, fullNode: NodeTypeFullNode,
+		}},
+	}
+	for _, test := range tests {
+		client := &fakeRPCClient{respond: func(method string, args interface{}) (interface{}, *jrpc.RPCError) {
+			switch method {
+			case "theta.GetStatus":
+				return cmn.GetStatusResult{
+					Syncing:                    test.syncing,
+					SnapshotBlockHeight:        10,
+					LatestFinalizedBlockHeight: test.finalized,
+					LatestFinalizedBlockTime:   new(common.JSONBig),
+					CurrentHeight:              1000,
+				}, nil
+			case "theta.GetPeers":
+				peers := []string{validator, guardian, fullNode}
+				if !args.(GetPeersArgs).SkipEdgeNode {
+					peers = append(peers, edgeNode)
+				}
+				return GetPeersResult{Peers: peers}, nil
+			case "theta.GetGcpByHeight":
+				return map[string]interface{}{"BlockHashGcpPairs": []interface{}{map[string]interface{}{
+					"Gcp": map[string]interface{}{"SortedGuardians": []interface{}{map[string]interface{}{"Holder": guardian}}},
+				}}}, nil
+			case "theta.GetVcpByHeight":
+				return map[string]interface{}{"BlockHashVcpPairs": []interface{}{map[string]interface{}{
+					"Vcp": map[string]interface{}{"SortedCandidates": []interface{}{map[string]interface{}{"Holder": validator}}},
+				}}}, nil
+			}
+			return nil, &jrpc.RPCError{Code: -32601, Message: "unexpected method " + method}
+		}}
+		// the state is known not to be pruned, the oldest block is the snapshot
+		s := &networkAPIService{client: client, probedAt: time.Now()}
+
+		resp, terr := s.NetworkStatus(context.Background(), &types.NetworkRequest{
+			NetworkIdentifier: testNetworkIdentifier(),
+			Metadata:          map[string]interface{}{"skip_edge_node": test.skipEdgeNode},
+		})
+		if terr != nil {
+			t.Errorf("%v: unexpected error %v", test.name, terr.Message)
+			continue
+		}
+		if *resp.SyncStatus.Stage != test.stage {
+			t.Errorf("%v: expected stage %v, got %v", test.name, test.stage, *resp.SyncStatus.Stage)
+		}
+		if len(resp.Peers) != len(test.nodeTypes) {
+			t.Errorf("%v: expected peers %v, got %v", test.name, test.nodeTypes, resp.Peers)
+			continue
+		}
+		for _, peer := range resp.Peers {
+			if nodeType := test.nodeTypes[peer.PeerID]; peer.Metadata["node_type"] != nodeType {
+				t.Errorf("%v: expected %v to be a %v, got %v", test.name, peer.PeerID, nodeType, peer.Metadata["node_type"])
+			}
+			if peer.Metadata["address"] != peer.PeerID {
+				t.Errorf("%v: expected address %v, got %v", test.name, peer.PeerID, peer.Metadata["address"])
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+)
+
+type requestMetadataKey struct{}
+
+// RequestMetadataMiddleware makes the optional top-level "metadata" object of a request body
+// available to the services, for the endpoints whose Rosetta request type has no metadata.
+func RequestMetadataMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.Body != nil {
+			body, err := ioutil.ReadAll(r.Body)
+			r.Body.Close()
+			if err == nil {
+				var req struct {
+					Metadata map[string]interface{} `json:"metadata"`
+				}
+				if json.Unmarshal(body, &req) == nil && req.Metadata != nil {
+					r = r.WithContext(context.WithValue(r.Context(), requestMetadataKey{}, req.Metadata))
+				}
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// getRequestMetadata returns the request metadata captured by RequestMetadataMiddleware.
+func getRequestMetadata(ctx context.Context) map[string]interface{} {
+	if meta, ok := ctx.Value(requestMetadataKey{}).(map[string]interface{}); ok {
+		return meta
+	}
+	return map[string]interface{}{}
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+
+	cmn "github.com/thetatoken/theta-rosetta-rpc-adaptor/common"
+)
+
+// backend holds the services of one Theta network or subchain.
+type backend struct {
+	networkIdentifier *types.NetworkIdentifier
+	network           server.NetworkAPIServicer
+	account           server.AccountAPIServicer
+	block             server.BlockAPIServicer
+	mempool           server.MempoolAPIServicer
+	construction      server.ConstructionAPIServicer
+	call              server.CallAPIServicer
+	stream            *StreamServer
+}
+
+// networkKey identifies a network, or a subchain as "<chain ID>/<subchain ID>".
+func networkKey(ni *types.NetworkIdentifier) string {
+	key := strings.ToLower(ni.Network)
+	if ni.SubNetworkIdentifier != nil {
+		key += "/" + strings.ToLower(ni.SubNetworkIdentifier.Network)
+	}
+	return key
+}
+
+// networkRouter implements all the Rosetta servicers, passing each request to the backend of
+// the network, or subchain, named by its NetworkIdentifier.
+type networkRouter struct {
+	keys     []string
+	backends map[string]*backend
+}
+
+func newNetworkRouter() *networkRouter {
+	return &networkRouter{
+		backends: make(map[string]*backend),
+	}
+}
+
+func (r *networkRouter) addBackend(b *backend) {
+	key := networkKey(b.networkIdentifier)
+	r.keys = append(r.keys, key)
+	r.backends[key] = b
+}
+
+func (r *networkRouter) route(ni *types.NetworkIdentifier) (*backend, *types.Error) {
+	if ni == nil {
+		return nil, cmn.ErrMissingNID
+	}
+	if !strings.EqualFold(ni.Blockchain, cmn.ChainName) {
+		return nil, cmn.ErrInvalidBlockchain
+	}
+	b, ok := r.backends[networkKey(ni)]
+	if !ok {
+		if ni.SubNetworkIdentifier != nil {
+			return nil, cmn.ErrInvalidSubnetwork
+		}
+		return nil, cmn.ErrInvalidNetwork
+	}
+	return b, nil
+}
+
+// NetworkList implements the /network/list endpoint, listing the networks of all backends.
+func (r *networkRouter) NetworkList(
+	ctx context.Context,
+	request *types.MetadataRequest,
+) (*types.NetworkListResponse, *types.Error) {
+	resp := &types.NetworkListResponse{
+		NetworkIdentifiers: make([]*types.NetworkIdentifier, 0),
+	}
+	for _, key := range r.keys {
+		list, err := r.backends[key].network.NetworkList(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		resp.NetworkIdentifiers = append(resp.NetworkIdentifiers, list.NetworkIdentifiers...)
+	}
+	return resp, nil
+}
+
+func (r *networkRouter) NetworkStatus(
+	ctx context.Context,
+	request *types.NetworkRequest,
+) (*types.NetworkStatusResponse, *types.Error) {
+	b, err := r.route(request.NetworkIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return b.network.NetworkStatus(ctx, request)
+}
+
+func (r *networkRouter) NetworkOptions(
+	ctx context.Context,
+	request *types.NetworkRequest,
+) (*types.NetworkOptionsResponse, *types.Error) {
+	b, err := r.route(request.NetworkIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return b.network.NetworkOptions(ctx, request)
+}
+
+func (r *networkRouter) AccountBalance(
+	ctx context.Context,
+	request *types.AccountBalanceRequest,
+) (*types.AccountBalanceResponse, *types.Error) {
+	b, err := r.route(request.NetworkIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return b.account.AccountBalance(ctx, request)
+}
+
+func (r *networkRouter) AccountCoins(
+	ctx context.Context,
+	request *types.AccountCoinsRequest,
+) (*types.AccountCoinsResponse, *types.Error) {
+	b, err := r.route(request.NetworkIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return b.account.AccountCoins(ctx, request)
+}
+
+func (r *networkRouter) Block(
+	ctx context.Context,
+	request *types.BlockRequest,
+) (*types.BlockResponse, *types.Error) {
+	b, err := r.route(request.NetworkIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return b.block.Block(ctx, request)
+}
+
+func (r *networkRouter) BlockTransaction(
+	ctx context.Context,
+	request *types.BlockTransactionRequest,
+) (*types.BlockTransactionResponse, *types.Error) {
+	b, err := r.route(request.NetworkIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return b.block.BlockTransaction(ctx, request)
+}
+
+func (r *networkRouter) Mempool(
+	ctx context.Context,
+	request *types.NetworkRequest,
+) (*types.MempoolResponse, *types.Error) {
+	b, err := r.route(request.NetworkIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return b.mempool.Mempool(ctx, request)
+}
+
+func (r *networkRouter) MempoolTransaction(
+	ctx context.Context,
+	request *types.MempoolTransactionRequest,
+) (*types.MempoolTransactionResponse, *types.Error) {
+	b, err := r.route(request.NetworkIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return b.mempool.MempoolTransaction(ctx, request)
+}
+
+func (r *networkRouter) ConstructionDerive(
+	ctx context.Context,
+	request *types.ConstructionDeriveRequest,
+) (*types.ConstructionDeriveResponse, *types.Error) {
+	b, err := r.route(request.NetworkIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return b.construction.ConstructionDerive(ctx, request)
+}
+
+func (r *networkRouter) ConstructionPreprocess(
+	ctx context.Context,
+	request *types.ConstructionPreprocessRequest,
+) (*types.ConstructionPreprocessResponse, *types.Error) {
+	b, err := r.route(request.NetworkIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return b.construction.ConstructionPreprocess(ctx, request)
+}
+
+func (r *networkRouter) ConstructionMetadata(
+	ctx context.Context,
+	request *types.ConstructionMetadataRequest,
+) (*types.ConstructionMetadataResponse, *types.Error) {
+	b, err := r.route(request.NetworkIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return b.construction.ConstructionMetadata(ctx, request)
+}
+
+func (r *networkRouter) ConstructionPayloads(
+	ctx context.Context,
+	request *types.ConstructionPayloadsRequest,
+) (*types.ConstructionPayloadsResponse, *types.Error) {
+	b, err := r.route(request.NetworkIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return b.construction.ConstructionPayloads(ctx, request)
+}
+
+func (r *networkRouter) ConstructionParse(
+	ctx context.Context,
+	request *types.ConstructionParseRequest,
+) (*types.ConstructionParseResponse, *types.Error) {
+	b, err := r.route(request.NetworkIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return b.construction.ConstructionParse(ctx, request)
+}
+
+func (r *networkRouter) ConstructionCombine(
+	ctx context.Context,
+	request *types.ConstructionCombineRequest,
+) (*types.ConstructionCombineResponse, *types.Error) {
+	b, err := r.route(request.NetworkIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return b.construction.ConstructionCombine(ctx, request)
+}
+
+func (r *networkRouter) ConstructionHash(
+	ctx context.Context,
+	request *types.ConstructionHashRequest,
+) (*types.TransactionIdentifierResponse, *types.Error) {
+	b, err := r.route(request.NetworkIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return b.construction.ConstructionHash(ctx, request)
+}
+
+func (r *networkRouter) ConstructionSubmit(
+	ctx context.Context,
+	request *types.ConstructionSubmitRequest,
+) (*types.TransactionIdentifierResponse, *types.Error) {
+	b, err := r.route(request.NetworkIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return b.construction.ConstructionSubmit(ctx, request)
+}
+
+func (r *networkRouter) Call(
+	ctx context.Context,
+	request *types.CallRequest,
+) (*types.CallResponse, *types.Error) {
+	b, err := r.route(request.NetworkIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return b.call.Call(ctx, request)
+}
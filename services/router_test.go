@@ -0,0 +1,60 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+
+	cmn "github.com/thetatoken/theta-rosetta-rpc-adaptor/common"
+)
+
+func TestNetworkRoute(t *testing.T) {
+	mainnet := &types.NetworkIdentifier{Blockchain: cmn.ChainName, Network: "mainnet"}
+	testnet := &types.NetworkIdentifier{Blockchain: cmn.ChainName, Network: "testnet"}
+	subchain := &types.NetworkIdentifier{
+		Blockchain:           cmn.ChainName,
+		Network:              "mainnet",
+		SubNetworkIdentifier: &types.SubNetworkIdentifier{Network: "tsub360777"},
+	}
+
+	r := newNetworkRouter()
+	for _, ni := range []*types.NetworkIdentifier{mainnet, testnet, subchain} {
+		r.addBackend(&backend{networkIdentifier: ni})
+	}
+
+	tests := []struct {
+		name    string
+		network *types.NetworkIdentifier
+		backend *types.NetworkIdentifier
+		err     *types.Error
+	}{
+		{"main chain", mainnet, mainnet, nil},
+		{"other main chain", testnet, testnet, nil},
+		{"case insensitive", &types.NetworkIdentifier{Blockchain: "THETA", Network: "MainNet"}, mainnet, nil},
+		{"subchain", subchain, subchain, nil},
+		{"unknown network", &types.NetworkIdentifier{Blockchain: cmn.ChainName, Network: "privatenet"}, nil, cmn.ErrInvalidNetwork},
+		{"unknown subchain", &types.NetworkIdentifier{
+			Blockchain:           cmn.ChainName,
+			Network:              "testnet",
+			SubNetworkIdentifier: &types.SubNetworkIdentifier{Network: "tsub360777"},
+		}, nil, cmn.ErrInvalidSubnetwork},
+		{"other blockchain", &types.NetworkIdentifier{Blockchain: "ethereum", Network: "mainnet"}, nil, cmn.ErrInvalidBlockchain},
+		{"missing network", nil, nil, cmn.ErrMissingNID},
+	}
+	for _, test := range tests {
+		b, terr := r.route(test.network)
+		if test.err != nil {
+			if terr == nil || terr.Code != test.err.Code {
+				t.Errorf("%v: expected %v, got %v", test.name, test.err.Message, terr)
+			}
+			continue
+		}
+		if terr != nil {
+			t.Errorf("%v: unexpected error %v", test.name, terr.Message)
+			continue
+		}
+		if b.networkIdentifier != test.backend {
+			t.Errorf("%v: routed to %v", test.name, networkKey(b.networkIdentifier))
+		}
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -22,13 +25,20 @@ import (
 var logger *log.Entry = log.WithFields(log.Fields{"prefix": "rpc"})
 
 func StartServers() error {
-	client := jrpc.NewClient(cmn.GetThetaRPCEndpoint())
-
-	router, err := NewThetaRouter(client)
+	networks, err := cmn.GetNetworkConfigs()
+	if err != nil {
+		logger.Fatalf("ERROR: Invalid network config: %v\n", err)
+	}
+	router, streams, err := NewThetaRouter(networks)
 	if err != nil {
 		logger.Fatalf("ERROR: Failed to init router: %v\n", err)
 	}
 
+	// the notifications poll the node, so no stream is served offline
+	if strings.EqualFold(cmn.CfgRosettaModeOnline, viper.GetString(cmn.CfgRosettaMode)) {
+		startStreams(streams)
+	}
+
 	httpAddr := viper.GetString(cmn.CfgRPCHttpAddress)
 	httpPort := viper.GetString(cmn.CfgRPCHttpPort)
 	httpEndpoint := fmt.Sprintf("%v:%v", httpAddr, httpPort)
@@ -45,60 +55,185 @@ func StopServers() error {
 	return nil
 }
 
+// startStreams serves the websocket notifications of each network at /<chain ID>, of each
+// subchain at /<chain ID>/<subchain ID>, and of the default network also at /.
+func startStreams(streams map[string]*StreamServer) {
+	wsAddr := viper.GetString(cmn.CfgRPCWSAddress)
+	wsPort := viper.GetString(cmn.CfgRPCWSPort)
+	wsEndpoint := fmt.Sprintf("%v:%v", wsAddr, wsPort)
+
+	wsMux := http.NewServeMux()
+	for i, chainID := range cmn.GetChainIds() {
+		streams[chainID].Start()
+		wsMux.Handle("/"+chainID, streams[chainID])
+		if i == 0 {
+			wsMux.Handle("/", streams[chainID])
+		}
+		for _, subchainID := range cmn.GetSubchainIds(chainID) {
+			path := chainID + "/" + subchainID
+			streams[path].Start()
+			wsMux.Handle("/"+path, streams[path])
+		}
+	}
+
+	go func() {
+		logger.Infof("Started websocket listening at: %v\n", wsEndpoint)
+		if err := http.ListenAndServe(wsEndpoint, wsMux); err != nil {
+			logger.Fatalf("Theta Rosetta Adaptor websocket server exited with error: %v\n", err)
+		}
+	}()
+}
+
 // NewThetaRouter returns a Mux http.Handler from a collection of
-// Rosetta service controllers.
-func NewThetaRouter(client jrpc.RPCClient) (http.Handler, error) {
-	status, err := cmn.GetStatus(client)
-	if err != nil {
-		return nil, err
+// Rosetta service controllers, along with the websocket StreamServer of each network.
+func NewThetaRouter(networks []cmn.NetworkConfig) (http.Handler, map[string]*StreamServer, error) {
+	router := newNetworkRouter()
+	streams := make(map[string]*StreamServer)
+	var chainIDs []string
+	var supportedNetworks []*types.NetworkIdentifier
+
+	for _, network := range networks {
+		b, err := newBackend(network, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		chainID := b.networkIdentifier.Network
+		if _, ok := router.backends[networkKey(b.networkIdentifier)]; ok {
+			return nil, nil, fmt.Errorf("network %v configured more than once", chainID)
+		}
+
+		router.addBackend(b)
+		streams[chainID] = b.stream
+		chainIDs = append(chainIDs, chainID)
+		supportedNetworks = append(supportedNetworks, b.networkIdentifier)
+
+		// subchains are served under the chain ID the node reported
+		network.ChainID = chainID
+		var subchainIDs []string
+		for i := range network.Subchains {
+			sb, err := newBackend(network, &network.Subchains[i])
+			if err != nil {
+				return nil, nil, err
+			}
+			subchainID := sb.networkIdentifier.SubNetworkIdentifier.Network
+			if _, ok := router.backends[networkKey(sb.networkIdentifier)]; ok {
+				return nil, nil, fmt.Errorf("subchain %v of %v configured more than once", subchainID, chainID)
+			}
+
+			router.addBackend(sb)
+			streams[chainID+"/"+subchainID] = sb.stream
+			subchainIDs = append(subchainIDs, subchainID)
+			supportedNetworks = append(supportedNetworks, sb.networkIdentifier)
+		}
+		cmn.SetSubchainIds(chainID, subchainIDs)
 	}
-	cmn.SetChainId(status.ChainID)
+	cmn.SetChainIds(chainIDs)
 
 	asserter, err := asserter.NewServer(
 		cmn.TxOpTypes(),
 		true,
-		[]*types.NetworkIdentifier{
-			{
-				Blockchain: cmn.ChainName,
-				Network:    status.ChainID,
-			},
-		},
-		[]string{},
-		false,
+		supportedNetworks,
+		CallMethods(),
+		true,
 	)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	networkAPIController := server.NewNetworkAPIController(router, asserter)
+	accountAPIController := server.NewAccountAPIController(router, asserter)
+	blockAPIController := server.NewBlockAPIController(router, asserter)
+	memPoolAPIController := server.NewMempoolAPIController(router, asserter)
+	constructionAPIController := server.NewConstructionAPIController(router, asserter)
+	callAPIController := server.NewCallAPIController(router, asserter)
+	r := server.NewRouter(networkAPIController, accountAPIController, blockAPIController, memPoolAPIController, constructionAPIController, callAPIController)
+	return server.CorsMiddleware(server.LoggerMiddleware(RequestMetadataMiddleware(r))), streams, nil
+}
+
+// newBackend connects to the node of a network, or of one of its subchains, and creates the
+// services serving it. Subchain nodes expose the same RPC as the main chain nodes.
+func newBackend(network cmn.NetworkConfig, subchain *cmn.SubchainConfig) (*backend, error) {
+	rpcEndpoint, chainID, dataPath := network.RPCEndpoint, network.ChainID, network.DataPath
+	if subchain != nil {
+		rpcEndpoint, chainID, dataPath = subchain.RPCEndpoint, subchain.ChainID, subchain.DataPath
 	}
+	client := jrpc.NewClient(rpcEndpoint)
 
-	needQueryReturnStakes := false
-	if _, err := os.Stat("/data/return_stakes"); errors.Is(err, os.ErrNotExist) {
-		needQueryReturnStakes = true
+	status, err := cmn.GetStatus(client)
+	if err != nil {
+		// offline, construction only needs the configured chain ID
+		if chainID == "" || strings.EqualFold(cmn.CfgRosettaModeOnline, viper.GetString(cmn.CfgRosettaMode)) {
+			return nil, err
+		}
+	} else if chainID == "" {
+		chainID = status.ChainID
+	} else if !strings.EqualFold(chainID, status.ChainID) {
+		return nil, fmt.Errorf("node at %v serves chain %v instead of %v", rpcEndpoint, status.ChainID, chainID)
 	}
 
-	db, err := cmn.NewLDBDatabase("/data/return_stakes", 64, 0)
-	stakeService := cmn.NewStakeService(client, db)
+	networkIdentifier := &types.NetworkIdentifier{
+		Blockchain: cmn.ChainName,
+		Network:    chainID,
+	}
+	if subchain != nil {
+		networkIdentifier.Network = network.ChainID
+		networkIdentifier.SubNetworkIdentifier = &types.SubNetworkIdentifier{Network: chainID}
+		cmn.SetSubchainTokens(chainID, subchain.Tokens)
+	}
 
-	// populate kvstore for vcp/gcp/eenp stakes having withdrawn:true
-	if needQueryReturnStakes {
-		stakeService.GenStakesForSnapshot()
+	// the return stakes DB is only read when serving blocks, so it is neither opened nor created
+	// offline
+	var db *cmn.LDBDatabase
+	var stakeService *cmn.StakeService
+	if strings.EqualFold(cmn.CfgRosettaModeOnline, viper.GetString(cmn.CfgRosettaMode)) {
+		dbPath := filepath.Join(dataPath, "return_stakes")
+
+		needQueryReturnStakes := false
+		if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+			needQueryReturnStakes = true
+		}
+
+		db, err = cmn.NewLDBDatabase(dbPath, 64, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open return stakes DB at %v: %v", dbPath, err)
+		}
+		stakeService = cmn.NewStakeService(client, db)
+
+		// populate kvstore for vcp/gcp/eenp stakes having withdrawn:true, subchains have no
+		// validator, guardian or elite edge node stakes
+		if needQueryReturnStakes && subchain == nil {
+			stakeService.GenStakesForSnapshot()
+		}
+
+		// //temp
+		// iter := db.NewIterator()
+		// for iter.Next() {
+		// 	key := iter.Key()
+		// 	returnStakeTxs := cmn.ReturnStakeTxs{}
+		// 	kvstore := cmn.NewKVStore(db)
+		// 	kvstore.Get(key, &returnStakeTxs)
+		// }
+		// iter.Release()
+		// err = iter.Error()
 	}
 
-	// //temp
-	// iter := db.NewIterator()
-	// for iter.Next() {
-	// 	key := iter.Key()
-	// 	returnStakeTxs := cmn.ReturnStakeTxs{}
-	// 	kvstore := cmn.NewKVStore(db)
-	// 	kvstore.Get(key, &returnStakeTxs)
-	// }
-	// iter.Release()
-	// err = iter.Error()
-
-	networkAPIController := server.NewNetworkAPIController(NewNetworkAPIService(client), asserter)
-	accountAPIController := server.NewAccountAPIController(NewAccountAPIService(client), asserter)
-	blockAPIController := server.NewBlockAPIController(NewBlockAPIService(client, db, stakeService), asserter)
-	memPoolAPIController := server.NewMempoolAPIController(NewMemPoolAPIService(client), asserter)
-	constructionAPIController := server.NewConstructionAPIController(NewConstructionAPIService(client), asserter)
-	r := server.NewRouter(networkAPIController, accountAPIController, blockAPIController, memPoolAPIController, constructionAPIController)
-	return server.CorsMiddleware(server.LoggerMiddleware(r)), nil
+	nonceTracker := cmn.NewNonceTracker(
+		time.Duration(viper.GetInt64(cmn.CfgRosettaNonceReservationTTLSecs))*time.Second,
+		time.Duration(viper.GetInt64(cmn.CfgRosettaNonceBuildReservationTTLSecs))*time.Second,
+	)
+	pendingTxs := cmn.NewPendingTxCache(time.Duration(viper.GetInt64(cmn.CfgRosettaNonceReservationTTLSecs)) * time.Second)
+
+	blockAPIService := NewBlockAPIService(client, db, stakeService)
+	memPoolAPIService := NewMemPoolAPIService(client, pendingTxs)
+
+	return &backend{
+		networkIdentifier: networkIdentifier,
+		network:           NewNetworkAPIService(client, networkIdentifier),
+		account:           NewAccountAPIService(client),
+		block:             blockAPIService,
+		mempool:           memPoolAPIService,
+		construction:      NewConstructionAPIService(client, chainID, nonceTracker, pendingTxs),
+		call:              NewCallAPIService(client),
+		stream:            NewStreamServer(client, networkIdentifier, blockAPIService, memPoolAPIService),
+	}, nil
 }
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	cmn "github.com/thetatoken/theta-rosetta-rpc-adaptor/common"
+)
+
+func TestNewBackendUnreachableNode(t *testing.T) {
+	defer viper.Set(cmn.CfgRosettaMode, nil)
+
+	// nothing listens on port 1
+	const rpcEndpoint = "http://127.0.0.1:1/rpc"
+
+	tests := []struct {
+		name    string
+		mode    string
+		chainID string
+		valid   bool
+	}{
+		{"offline with a chain ID", cmn.CfgRosettaModeOffline, "privatenet", true},
+		{"offline without a chain ID", cmn.CfgRosettaModeOffline, "", false},
+		{"online", cmn.CfgRosettaModeOnline, "privatenet", false},
+	}
+	for _, test := range tests {
+		viper.Set(cmn.CfgRosettaMode, test.mode)
+		dataPath, err := ioutil.TempDir("", "rosetta")
+		if err != nil {
+			t.Fatalf("failed to create the data path: %v", err)
+		}
+		defer os.RemoveAll(dataPath)
+
+		b, err := newBackend(cmn.NetworkConfig{ChainID: test.chainID, RPCEndpoint: rpcEndpoint, DataPath: dataPath}, nil)
+		if (err == nil) != test.valid {
+			t.Errorf("%v: expected valid %v, got %v", test.name, test.valid, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if b.networkIdentifier.Network != test.chainID {
+			t.Errorf("%v: expected network %v, got %v", test.name, test.chainID, b.networkIdentifier.Network)
+		}
+		// the return stakes DB is only needed online
+		if _, err := os.Stat(filepath.Join(dataPath, "return_stakes")); !os.IsNotExist(err) {
+			t.Errorf("%v: return stakes DB created offline", test.name)
+		}
+	}
+}
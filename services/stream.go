@@ -0,0 +1,594 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/gorilla/websocket"
+	jrpc "github.com/ybbus/jsonrpc"
+
+	cmn "github.com/thetatoken/theta-rosetta-rpc-adaptor/common"
+	"github.com/thetatoken/theta/common"
+)
+
+const (
+	// StreamTopicBlocks notifies the new finalized blocks.
+	StreamTopicBlocks = "blocks"
+	// StreamTopicMempool notifies the txs entering the mempool.
+	StreamTopicMempool = "mempool"
+	// StreamTopicTxStatus notifies the status changes of a list of txs.
+	StreamTopicTxStatus = "tx_status"
+
+	streamPollInterval = time.Second
+	streamSendBuffer   = 256
+	streamWriteTimeout = 10 * time.Second
+)
+
+// streamRequest is a message sent by a websocket client, either
+//
+//	{"id": 1, "method": "subscribe", "params": {"topic": "blocks", "full": true, "accounts": [...]}}
+//	{"id": 2, "method": "subscribe", "params": {"topic": "tx_status", "tx_hashes": [...]}}
+//	{"id": 3, "method": "unsubscribe", "params": {"subscription": "1"}}
+type streamRequest struct {
+	ID     interface{}  `json:"id"`
+	Method string       `json:"method"`
+	Params streamParams `json:"params"`
+}
+
+type streamParams struct {
+	Topic        string   `json:"topic"`
+	Full         bool     `json:"full"`
+	Accounts     []string `json:"accounts"`
+	TxHashes     []string `json:"tx_hashes"`
+	Subscription string   `json:"subscription"`
+}
+
+type streamResponse struct {
+	ID     interface{}  `json:"id"`
+	Result interface{}  `json:"result,omitempty"`
+	Error  *types.Error `json:"error,omitempty"`
+}
+
+type streamNotification struct {
+	Subscription string      `json:"subscription"`
+	Topic        string      `json:"topic"`
+	Data         interface{} `json:"data"`
+}
+
+type streamBlockData struct {
+	BlockIdentifier        *types.BlockIdentifier         `json:"block_identifier"`
+	Block                  *types.Block                   `json:"block,omitempty"`
+	TransactionIdentifiers []*types.TransactionIdentifier `json:"transaction_identifiers,omitempty"`
+}
+
+type streamMempoolData struct {
+	TransactionIdentifier *types.TransactionIdentifier `json:"transaction_identifier"`
+	Transaction           *types.Transaction           `json:"transaction,omitempty"`
+	// Unfiltered is set when the tx could not be decoded, so the accounts filter was not applied
+	Unfiltered bool `json:"unfiltered,omitempty"`
+}
+
+type streamTxStatusData struct {
+	TransactionIdentifier *types.TransactionIdentifier `json:"transaction_identifier"`
+	Status                cmn.TxStatus                 `json:"status"`
+	BlockIdentifier       *types.BlockIdentifier       `json:"block_identifier,omitempty"`
+}
+
+type txStatusResult struct {
+	BlockHash   common.Hash       `json:"block_hash"`
+	BlockHeight common.JSONUint64 `json:"block_height"`
+	Status      cmn.TxStatus      `json:"status"`
+}
+
+type streamSubscription struct {
+	id         string
+	topic      string
+	full       bool
+	accounts   map[string]bool
+	txStatuses map[string]cmn.TxStatus
+}
+
+type streamSubscriber struct {
+	conn          *websocket.Conn
+	send          chan []byte
+	subscriptions map[string]*streamSubscription
+}
+
+// StreamServer pushes new finalized blocks, mempool txs and tx status changes to websocket
+// subscribers. It polls the node and fans the changes out to the matching subscriptions.
+type StreamServer struct {
+	client            jrpc.RPCClient
+	networkIdentifier *types.NetworkIdentifier
+	blockService      server.BlockAPIServicer
+	mempoolService    server.MempoolAPIServicer
+	upgrader          websocket.Upgrader
+
+	mu          sync.Mutex
+	subscribers map[*streamSubscriber]bool
+	nextID      uint64
+
+	lastHeight common.JSONUint64
+	mempoolTxs map[string]bool
+}
+
+// NewStreamServer creates a new instance of a StreamServer.
+func NewStreamServer(client jrpc.RPCClient, networkIdentifier *types.NetworkIdentifier, blockService server.BlockAPIServicer, mempoolService server.MempoolAPIServicer) *StreamServer {
+	return &StreamServer{
+		client:            client,
+		networkIdentifier: networkIdentifier,
+		blockService:      blockService,
+		mempoolService:    mempoolService,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		subscribers: make(map[*streamSubscriber]bool),
+	}
+}
+
+// Start starts polling the node for the subscribed topics.
+func (ss *StreamServer) Start() {
+	go ss.run()
+}
+
+// ServeHTTP upgrades the connection to a websocket and serves its subscriptions.
+func (ss *StreamServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := ss.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.Debugf("Failed to upgrade websocket connection: %v", err)
+		return
+	}
+
+	sub := &streamSubscriber{
+		conn:          conn,
+		send:          make(chan []byte, streamSendBuffer),
+		subscriptions: make(map[string]*streamSubscription),
+	}
+
+	ss.mu.Lock()
+	ss.subscribers[sub] = true
+	ss.mu.Unlock()
+
+	go ss.writeLoop(sub)
+	ss.readLoop(sub)
+}
+
+func (ss *StreamServer) readLoop(sub *streamSubscriber) {
+	defer ss.removeSubscriber(sub)
+
+	for {
+		_, msg, err := sub.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var req streamRequest
+		if err := json.Unmarshal(msg, &req); err != nil {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += "malformed request"
+			ss.reply(sub, streamResponse{Error: terr})
+			continue
+		}
+
+		switch req.Method {
+		case "subscribe":
+			id, terr := ss.subscribe(sub, req.Params)
+			if terr != nil {
+				ss.reply(sub, streamResponse{ID: req.ID, Error: terr})
+				continue
+			}
+			ss.reply(sub, streamResponse{ID: req.ID, Result: map[string]string{"subscription": id}})
+		case "unsubscribe":
+			ss.mu.Lock()
+			_, ok := sub.subscriptions[req.Params.Subscription]
+			delete(sub.subscriptions, req.Params.Subscription)
+			ss.mu.Unlock()
+			ss.reply(sub, streamResponse{ID: req.ID, Result: ok})
+		default:
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += fmt.Sprintf("unknown method %v", req.Method)
+			ss.reply(sub, streamResponse{ID: req.ID, Error: terr})
+		}
+	}
+}
+
+func (ss *StreamServer) writeLoop(sub *streamSubscriber) {
+	for msg := range sub.send {
+		sub.conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if err := sub.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			ss.removeSubscriber(sub)
+			return
+		}
+	}
+}
+
+func (ss *StreamServer) subscribe(sub *streamSubscriber, params streamParams) (string, *types.Error) {
+	subscription := &streamSubscription{
+		topic: params.Topic,
+		full:  params.Full,
+	}
+
+	switch params.Topic {
+	case StreamTopicBlocks, StreamTopicMempool:
+	case StreamTopicTxStatus:
+		if len(params.TxHashes) == 0 {
+			terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+			terr.Message += "tx_hashes are required for tx_status"
+			return "", terr
+		}
+		subscription.txStatuses = make(map[string]cmn.TxStatus)
+		for _, txHash := range params.TxHashes {
+			subscription.txStatuses[strings.ToLower(txHash)] = ""
+		}
+	default:
+		terr := cmn.CopyError(cmn.ErrInvalidInputParam)
+		terr.Message += fmt.Sprintf("unknown topic %v", params.Topic)
+		return "", terr
+	}
+
+	if len(params.Accounts) > 0 {
+		subscription.accounts = make(map[string]bool)
+		for _, addr := range params.Accounts {
+			if !common.IsHexAddress(addr) {
+				terr := cmn.CopyError(cmn.ErrInvalidAccountAddress)
+				terr.Message += fmt.Sprintf(": %v", addr)
+				return "", terr
+			}
+			subscription.accounts[strings.ToLower(addr)] = true
+		}
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.nextID++
+	subscription.id = fmt.Sprintf("%d", ss.nextID)
+	sub.subscriptions[subscription.id] = subscription
+	return subscription.id, nil
+}
+
+func (ss *StreamServer) reply(sub *streamSubscriber, resp streamResponse) {
+	msg, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.enqueue(sub, msg)
+}
+
+// enqueue queues a message for the subscriber, dropping a subscriber too slow to keep up.
+// It must be called with the lock held.
+func (ss *StreamServer) enqueue(sub *streamSubscriber, msg []byte) {
+	if !ss.subscribers[sub] {
+		return
+	}
+	select {
+	case sub.send <- msg:
+	default:
+		logger.Debugf("Dropping slow websocket subscriber %v", sub.conn.RemoteAddr())
+		ss.removeSubscriberLocked(sub)
+	}
+}
+
+func (ss *StreamServer) notify(sub *streamSubscriber, subscription *streamSubscription, data interface{}) {
+	msg, err := json.Marshal(streamNotification{
+		Subscription: subscription.id,
+		Topic:        subscription.topic,
+		Data:         data,
+	})
+	if err != nil {
+		return
+	}
+	ss.enqueue(sub, msg)
+}
+
+func (ss *StreamServer) removeSubscriber(sub *streamSubscriber) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.removeSubscriberLocked(sub)
+}
+
+func (ss *StreamServer) removeSubscriberLocked(sub *streamSubscriber) {
+	if !ss.subscribers[sub] {
+		return
+	}
+	delete(ss.subscribers, sub)
+	close(sub.send)
+	sub.conn.Close()
+}
+
+// activeTopics returns the topics having at least one subscription.
+func (ss *StreamServer) activeTopics() map[string]bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	topics := make(map[string]bool)
+	for sub := range ss.subscribers {
+		for _, subscription := range sub.subscriptions {
+			topics[subscription.topic] = true
+		}
+	}
+	return topics
+}
+
+func (ss *StreamServer) run() {
+	ticker := time.NewTicker(streamPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		topics := ss.activeTopics()
+
+		if topics[StreamTopicBlocks] {
+			ss.pollBlocks()
+		} else {
+			ss.lastHeight = 0
+		}
+
+		if topics[StreamTopicMempool] {
+			ss.pollMempool()
+		} else {
+			ss.mempoolTxs = nil
+		}
+
+		if topics[StreamTopicTxStatus] {
+			ss.pollTxStatuses()
+		}
+	}
+}
+
+// pollBlocks notifies the blocks finalized since the last poll. The first poll only records
+// the latest finalized height.
+func (ss *StreamServer) pollBlocks() {
+	status, err := cmn.GetStatus(ss.client)
+	if err != nil {
+		logger.Debugf("Failed to get status: %v", err)
+		return
+	}
+
+	latest := status.LatestFinalizedBlockHeight
+	if ss.lastHeight == 0 || latest < ss.lastHeight {
+		ss.lastHeight = latest
+		return
+	}
+
+	for height := ss.lastHeight + 1; height <= latest; height++ {
+		index := int64(height)
+		resp, terr := ss.blockService.Block(context.Background(), &types.BlockRequest{
+			NetworkIdentifier: ss.networkIdentifier,
+			BlockIdentifier:   &types.PartialBlockIdentifier{Index: &index},
+		})
+		if terr != nil {
+			logger.Debugf("Failed to get block %v: %v", height, terr.Message)
+			return
+		}
+		ss.notifyBlock(resp.Block)
+		ss.lastHeight = height
+	}
+}
+
+func (ss *StreamServer) notifyBlock(block *types.Block) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	for sub := range ss.subscribers {
+		for _, subscription := range sub.subscriptions {
+			if subscription.topic != StreamTopicBlocks {
+				continue
+			}
+
+			data := streamBlockData{BlockIdentifier: block.BlockIdentifier}
+			if subscription.accounts == nil {
+				if subscription.full {
+					data.Block = block
+				}
+				ss.notify(sub, subscription, data)
+				continue
+			}
+
+			var txs []*types.Transaction
+			for _, tx := range block.Transactions {
+				if matchAccounts(tx, subscription.accounts) {
+					txs = append(txs, tx)
+				}
+			}
+			if len(txs) == 0 {
+				continue
+			}
+			if subscription.full {
+				filtered := *block
+				filtered.Transactions = txs
+				data.Block = &filtered
+			} else {
+				for _, tx := range txs {
+					data.TransactionIdentifiers = append(data.TransactionIdentifiers, tx.TransactionIdentifier)
+				}
+			}
+			ss.notify(sub, subscription, data)
+		}
+	}
+}
+
+// pollMempool notifies the tx hashes which entered the mempool since the last poll. The
+// txs are only decoded when a subscription filters by account or asks for full txs.
+func (ss *StreamServer) pollMempool() {
+	rpcRes, rpcErr := ss.client.Call("theta.GetPendingTransactions", GetPendingTransactionsArgs{})
+
+	parse := func(jsonBytes []byte) (interface{}, error) {
+		pendingTxs := GetPendingTransactionsResult{}
+		err := json.Unmarshal(jsonBytes, &pendingTxs)
+		if err != nil {
+			return nil, err
+		}
+		return pendingTxs, nil
+	}
+
+	res, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse)
+	if err != nil {
+		logger.Debugf("Failed to get pending txs: %v", err)
+		return
+	}
+
+	mempoolTxs := make(map[string]bool)
+	var newTxHashes []string
+	for _, txHash := range res.(GetPendingTransactionsResult).TxHashes {
+		mempoolTxs[txHash] = true
+		if ss.mempoolTxs != nil && !ss.mempoolTxs[txHash] {
+			newTxHashes = append(newTxHashes, txHash)
+		}
+	}
+	ss.mempoolTxs = mempoolTxs
+
+	for _, txHash := range newTxHashes {
+		ss.notifyMempoolTx(txHash)
+	}
+}
+
+func (ss *StreamServer) notifyMempoolTx(txHash string) {
+	ss.mu.Lock()
+	var needTx bool
+	for sub := range ss.subscribers {
+		for _, subscription := range sub.subscriptions {
+			if subscription.topic == StreamTopicMempool && (subscription.full || subscription.accounts != nil) {
+				needTx = true
+			}
+		}
+	}
+	ss.mu.Unlock()
+
+	var tx *types.Transaction
+	if needTx {
+		resp, terr := ss.mempoolService.MempoolTransaction(context.Background(), &types.MempoolTransactionRequest{
+			NetworkIdentifier:     ss.networkIdentifier,
+			TransactionIdentifier: &types.TransactionIdentifier{Hash: txHash},
+		})
+		if terr == nil {
+			tx = resp.Transaction
+		}
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	for sub := range ss.subscribers {
+		for _, subscription := range sub.subscriptions {
+			if subscription.topic != StreamTopicMempool {
+				continue
+			}
+
+			data := streamMempoolData{TransactionIdentifier: &types.TransactionIdentifier{Hash: txHash}}
+			if subscription.accounts != nil {
+				// txs which cannot be decoded are notified unfiltered rather than dropped
+				if !isDecodedTx(tx) {
+					data.Unfiltered = true
+				} else if !matchAccounts(tx, subscription.accounts) {
+					continue
+				}
+			}
+			if subscription.full {
+				data.Transaction = tx
+			}
+			ss.notify(sub, subscription, data)
+		}
+	}
+}
+
+// pollTxStatuses notifies the status changes of the watched txs, not_found included once the
+// node no longer knows a tx. A tx stops being watched once finalized or abandoned.
+func (ss *StreamServer) pollTxStatuses() {
+	ss.mu.Lock()
+	txHashes := make(map[string]bool)
+	for sub := range ss.subscribers {
+		for _, subscription := range sub.subscriptions {
+			for txHash := range subscription.txStatuses {
+				txHashes[txHash] = true
+			}
+		}
+	}
+	ss.mu.Unlock()
+
+	for txHash := range txHashes {
+		rpcRes, rpcErr := ss.client.Call("theta.GetTransaction", GetTransactionArgs{
+			Hash: txHash,
+		})
+
+		parse := func(jsonBytes []byte) (interface{}, error) {
+			txResult := txStatusResult{}
+			err := json.Unmarshal(jsonBytes, &txResult)
+			if err != nil {
+				return nil, err
+			}
+			return txResult, nil
+		}
+
+		res, err := cmn.HandleThetaRPCResponse(rpcRes, rpcErr, parse)
+		if err != nil {
+			// the node reports an error for a tx it does not know, like a tx which left the mempool
+			// without being included. A failure to reach the node says nothing of the tx.
+			if rpcErr == nil && rpcRes != nil && rpcRes.Error != nil {
+				ss.notifyTxStatus(txHash, txStatusResult{Status: cmn.TxStatusNotFound})
+			}
+			continue
+		}
+		ss.notifyTxStatus(txHash, res.(txStatusResult))
+	}
+}
+
+func (ss *StreamServer) notifyTxStatus(txHash string, txResult txStatusResult) {
+	status := txResult.Status
+	if status == "" {
+		status = cmn.TxStatusNotFound
+	}
+
+	data := streamTxStatusData{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: txHash},
+		Status:                status,
+	}
+	if status == cmn.TxStatusFinalized {
+		data.BlockIdentifier = &types.BlockIdentifier{Index: int64(txResult.BlockHeight), Hash: txResult.BlockHash.Hex()}
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	for sub := range ss.subscribers {
+		for _, subscription := range sub.subscriptions {
+			prevStatus, ok := subscription.txStatuses[txHash]
+			if !ok || prevStatus == status {
+				continue
+			}
+			ss.notify(sub, subscription, data)
+			if status == cmn.TxStatusFinalized || status == cmn.TxStatusAbandoned {
+				delete(subscription.txStatuses, txHash)
+			} else {
+				subscription.txStatuses[txHash] = status
+			}
+		}
+	}
+}
+
+// isDecodedTx reports whether the operations of a mempool tx are known.
+func isDecodedTx(tx *types.Transaction) bool {
+	if tx == nil {
+		return false
+	}
+	unknown, _ := tx.Metadata["content_unknown"].(bool)
+	return !unknown
+}
+
+// matchAccounts reports whether an operation of the tx involves one of the accounts.
+func matchAccounts(tx *types.Transaction, accounts map[string]bool) bool {
+	for _, op := range tx.Operations {
+		if op.Account != nil && accounts[strings.ToLower(op.Account.Address)] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	jrpc "github.com/ybbus/jsonrpc"
+
+	cmn "github.com/thetatoken/theta-rosetta-rpc-adaptor/common"
+)
+
+func TestPollTxStatuses(t *testing.T) {
+	txHash := "0x3fa1c1c5d80f1a4cfca3b0bc4d0a0cd0a0fbc5f9b0e1d3e1dbe1c1b2ec5f6c7d"
+	var result *txStatusResult
+	var rpcErr *jrpc.RPCError
+	client := &fakeRPCClient{respond: func(method string, args interface{}) (interface{}, *jrpc.RPCError) {
+		return result, rpcErr
+	}}
+	ss := NewStreamServer(client, testNetworkIdentifier(), nil, nil)
+
+	sub := &streamSubscriber{send: make(chan []byte, streamSendBuffer), subscriptions: make(map[string]*streamSubscription)}
+	ss.subscribers[sub] = true
+	if _, terr := ss.subscribe(sub, streamParams{Topic: StreamTopicTxStatus, TxHashes: []string{txHash}}); terr != nil {
+		t.Fatalf("subscribe failed: %v", terr.Message)
+	}
+
+	// the steps run in order against the same subscription
+	notFound := &jrpc.RPCError{Code: -32000, Message: "Transaction is not found"}
+	tests := []struct {
+		name   string
+		result *txStatusResult
+		rpcErr *jrpc.RPCError
+		err    error
+		status cmn.TxStatus // empty when no notification is expected
+	}{
+		{"pending", &txStatusResult{Status: cmn.TxStatusPending}, nil, nil, cmn.TxStatusPending},
+		{"still pending", &txStatusResult{Status: cmn.TxStatusPending}, nil, nil, ""},
+		{"node unreachable", nil, nil, errors.New("connection refused"), ""},
+		{"dropped from the mempool", nil, notFound, nil, cmn.TxStatusNotFound},
+		{"still not found", nil, notFound, nil, ""},
+		{"abandoned", &txStatusResult{Status: cmn.TxStatusAbandoned}, nil, nil, cmn.TxStatusAbandoned},
+		{"no longer watched", &txStatusResult{Status: cmn.TxStatusPending}, nil, nil, ""},
+	}
+	for _, test := range tests {
+		result, rpcErr, client.err = test.result, test.rpcErr, test.err
+		ss.pollTxStatuses()
+
+		var status cmn.TxStatus
+		select {
+		case msg := <-sub.send:
+			notification := struct {
+				Data streamTxStatusData `json:"data"`
+			}{}
+			if err := json.Unmarshal(msg, &notification); err != nil {
+				t.Fatalf("%v: invalid notification: %v", test.name, err)
+			}
+			status = notification.Data.Status
+		default:
+		}
+		if status != test.status {
+			t.Errorf("%v: expected status %q, got %q", test.name, test.status, status)
+		}
+	}
+}